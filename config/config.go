@@ -1,13 +1,19 @@
 package config
 
 import (
+	"errors"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret és el secret de desenvolupament. Fora de desenvolupament no s'accepta
+const DefaultJWTSecret = "abcd1234"
+
 type Config struct {
+	AppEnv  string `env:"APP_ENV" envDefault:"production"`
 	DBHost  string `env:"DB_HOST" envDefault:"localhost"`
 	DBPort  string `env:"DB_PORT" envDefault:"5432"`
 	DBUser  string `env:"DB_USER" envDefault:"postgres"`
@@ -15,6 +21,48 @@ type Config struct {
 	DBName  string `env:"DB_NAME" envDefault:"postgres"`
 	ApiPort string `env:"API_PORT" envDefault:"8080"`
 	JWTSecret string `env:"JWT_SECRET" envDefault:"abcd1234"`
+	JWTPrivateKeyFile string `env:"JWT_PRIVATE_KEY_FILE"`
+	JWTVerificationKeyFiles []string `env:"JWT_VERIFICATION_KEY_FILES" envSeparator:","`
+	ApiURL string `env:"API_URL" envDefault:"https://api.perretes.zenith.ovh"`
+	FrontendURL string `env:"FRONTEND_URL" envDefault:"https://perretes.zenith.ovh"`
+	SMTPHost string `env:"SMTP_HOST"`
+	SMTPPort string `env:"SMTP_PORT" envDefault:"25"`
+	SMTPUser string `env:"SMTP_USER"`
+	SMTPPass string `env:"SMTP_PASS"`
+	SMTPFrom string `env:"SMTP_FROM" envDefault:"no-reply@perretes.zenith.ovh"`
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"1h"`
+	EmailVerificationTTL time.Duration `env:"EMAIL_VERIFICATION_TTL" envDefault:"48h"`
+	MagicLinkTTL time.Duration `env:"MAGIC_LINK_TTL" envDefault:"15m"`
+	RequireEmailVerification bool `env:"REQUIRE_EMAIL_VERIFICATION" envDefault:"false"`
+	AccessTokenTTL time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
+	PasswordMinLength int `env:"PASSWORD_MIN_LENGTH" envDefault:"10"`
+	PasswordRequireUpper bool `env:"PASSWORD_REQUIRE_UPPER" envDefault:"false"`
+	PasswordRequireLower bool `env:"PASSWORD_REQUIRE_LOWER" envDefault:"false"`
+	PasswordRequireDigit bool `env:"PASSWORD_REQUIRE_DIGIT" envDefault:"false"`
+	PasswordRequireSymbol bool `env:"PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+	PasswordHistory int `env:"PASSWORD_HISTORY" envDefault:"5"`
+	LoginDelayAfter int `env:"LOGIN_DELAY_AFTER" envDefault:"3"`
+	LoginLockAfter int `env:"LOGIN_LOCK_AFTER" envDefault:"10"`
+	LoginIPDelayAfter int `env:"LOGIN_IP_DELAY_AFTER" envDefault:"20"`
+	LoginIPLockAfter int `env:"LOGIN_IP_LOCK_AFTER" envDefault:"100"`
+	LoginLockout time.Duration `env:"LOGIN_LOCKOUT" envDefault:"15m"`
+	MagicLinkDelayAfter int `env:"MAGIC_LINK_DELAY_AFTER" envDefault:"1"`
+	MagicLinkLockAfter int `env:"MAGIC_LINK_LOCK_AFTER" envDefault:"5"`
+	MagicLinkIPDelayAfter int `env:"MAGIC_LINK_IP_DELAY_AFTER" envDefault:"10"`
+	MagicLinkIPLockAfter int `env:"MAGIC_LINK_IP_LOCK_AFTER" envDefault:"50"`
+	MagicLinkLockout time.Duration `env:"MAGIC_LINK_LOCKOUT" envDefault:"1h"`
+	MFAIssuer string `env:"MFA_ISSUER" envDefault:"Perretes"`
+	MFATokenTTL time.Duration `env:"MFA_TOKEN_TTL" envDefault:"5m"`
+	ImpersonationTTL time.Duration `env:"IMPERSONATION_TTL" envDefault:"15m"`
+	OIDCProvider string `env:"OIDC_PROVIDER" envDefault:"google"`
+	OIDCIssuerURL string `env:"OIDC_ISSUER_URL"`
+	OIDCClientID string `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL string `env:"OIDC_REDIRECT_URL"`
+	UserStatusCacheTTL time.Duration `env:"USER_STATUS_CACHE_TTL" envDefault:"30s"`
+	HealthReminderDays int `env:"HEALTH_REMINDER_DAYS" envDefault:"14"`
+	HealthReminderInterval time.Duration `env:"HEALTH_REMINDER_INTERVAL" envDefault:"1h"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -24,5 +72,16 @@ func LoadConfig() (*Config, error) {
 		log.Fatalf("failed to load config: %v", err)
 		return nil, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
+}
+
+// Validate comprova la configuració que no es pot deixar per defecte en producció
+func (c *Config) Validate() error {
+	if c.AppEnv != "development" && c.JWTPrivateKeyFile == "" && c.JWTSecret == DefaultJWTSecret {
+		return errors.New("refusing to start with the default JWT_SECRET outside development: set JWT_PRIVATE_KEY_FILE or JWT_SECRET, or APP_ENV=development")
+	}
+	return nil
 }
\ No newline at end of file
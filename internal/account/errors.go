@@ -0,0 +1,7 @@
+package account
+
+import "errors"
+
+var (
+	ErrMissingIdentity = errors.New("missing identity in token")
+)
@@ -0,0 +1,111 @@
+package account
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/courses"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/users"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	userService     users.UserService
+	customerService customers.CustomerService
+	courseService   courses.CourseService
+}
+
+func NewAccountHandler(userService users.UserService, customerService customers.CustomerService, courseService courses.CourseService) *AccountHandler {
+	return &AccountHandler{
+		userService:     userService,
+		customerService: customerService,
+		courseService:   courseService,
+	}
+}
+
+// GetMe retorna l'usuari autenticat
+func (h *AccountHandler) GetMe(c *gin.Context) {
+	id, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrMissingIdentity.Error()})
+		return
+	}
+	user, err := h.userService.FindByID(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetMyCourses retorna els cursos de l'usuari autenticat amb el seu progrés
+func (h *AccountHandler) GetMyCourses(c *gin.Context) {
+	id, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrMissingIdentity.Error()})
+		return
+	}
+	userCourses, err := h.courseService.FindCoursesByUserID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, userCourses)
+}
+
+// GetMyCustomer retorna la fitxa de client de l'usuari autenticat
+func (h *AccountHandler) GetMyCustomer(c *gin.Context) {
+	id, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrMissingIdentity.Error()})
+		return
+	}
+	customer, err := h.customerService.FindCustomerByUserID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if customer.ID == uuid.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": customers.ErrCustomerNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
+
+// ChangeMyPassword canvia la contrasenya de l'usuari autenticat. Cal indicar l'actual
+func (h *AccountHandler) ChangeMyPassword(c *gin.Context) {
+	id, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrMissingIdentity.Error()})
+		return
+	}
+	var request users.ChangeOwnPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.userService.ChangeOwnPassword(c.Request.Context(), id, request)
+	if err != nil {
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, users.ErrWrongPassword), errors.Is(err, users.ErrInvalidRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, users.ErrUserNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,95 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/testutil"
+	"perretes-api/internal/users"
+	"perretes-api/internal/users/userstest"
+	"perretes-api/middleware"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type noopVerifier struct{}
+
+func (noopVerifier) SendVerification(ctx context.Context, user users.User, email string) error {
+	return nil
+}
+
+type fakeCustomerService struct {
+	customers.CustomerService
+	customer customers.Customer
+}
+
+func (s *fakeCustomerService) FindCustomerByUserID(ctx context.Context, userID string) (customers.Customer, error) {
+	return s.customer, nil
+}
+
+// L'usuari surt del servei d'usuaris real, que el llegeix amb el hash de la
+// contrasenya com el repositori de veritat
+func TestAccountHandlerResponsesHidePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := userstest.NewUser("laia", "laia@example.com", "Laia-Gos-7341", roles.RoleCustomer)
+	userService := users.NewUserService(userstest.NewRepository(user), noopVerifier{}, passwordpolicy.Policy{HistorySize: 3})
+	userResponse, err := userService.FindByID(context.Background(), user.ID.String())
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	customer := customers.Customer{
+		ID:          uuid.New(),
+		Name:        "Laia",
+		Surname:     "Puig",
+		PhoneNumber: "600000000",
+		Email:       user.Email,
+		User:        userResponse,
+		IsActive:    true,
+		CreatedAt:   time.Now(),
+	}
+	handler := NewAccountHandler(userService, &fakeCustomerService{customer: customer}, nil)
+
+	router := gin.New()
+	// Fa el paper del middleware JWT, que deixa l'usuari del token al context
+	router.Use(func(c *gin.Context) {
+		c.Set("JWT_PAYLOAD", jwt.MapClaims{
+			middleware.IdentityKey: user.ID.String(),
+			"role":                 roles.RoleCustomer,
+		})
+		c.Set(middleware.IdentityKey, user.ID.String())
+		c.Next()
+	}, middleware.SetCaller())
+	RegisterRoutes(router.Group(""), handler)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"me", http.MethodGet, "/me", ""},
+		{"my customer", http.MethodGet, "/me/customer", ""},
+		{"change my password", http.MethodPut, "/me/password", `{"current_password":"Laia-Gos-7341","new_password":"Laia-Nou-9182"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			testutil.AssertNoSecrets(t, rec.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package account
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *AccountHandler) {
+	me := router.Group("/me")
+	{
+		me.GET("", handler.GetMe)
+		me.GET("/courses", handler.GetMyCourses)
+		me.GET("/customer", handler.GetMyCustomer)
+		me.PUT("/password", handler.ChangeMyPassword)
+	}
+}
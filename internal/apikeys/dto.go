@@ -0,0 +1,15 @@
+package apikeys
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKeyResponse porta la clau sencera. No es pot tornar a consultar
+type CreatedAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
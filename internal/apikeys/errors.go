@@ -0,0 +1,11 @@
+package apikeys
+
+import "errors"
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidID      = errors.New("invalid API key ID")
+	ErrInvalidScope   = errors.New("scopes must be permissions you have or that cover your own resources")
+	ErrInvalidExpiry  = errors.New("expires_at must be in the future")
+	ErrTooManyKeys    = errors.New("too many active API keys, revoke one first")
+)
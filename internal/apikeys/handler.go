@@ -0,0 +1,67 @@
+package apikeys
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/authz"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	service APIKeyService
+}
+
+func NewAPIKeyHandler(service APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+func (h *APIKeyHandler) GetMyAPIKeys(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	keys, err := h.service.FindByUserID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) CreateMyAPIKey(c *gin.Context) {
+	var request CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.CurrentUserID(c)
+	key, err := h.service.Create(c.Request.Context(), userID, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) DeleteMyAPIKey(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.Revoke(c.Request.Context(), userID, c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidScope), errors.Is(err, ErrInvalidExpiry):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrAPIKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrTooManyKeys):
+		status = http.StatusConflict
+	case errors.Is(err, authz.ErrForbidden):
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
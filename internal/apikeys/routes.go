@@ -0,0 +1,12 @@
+package apikeys
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *APIKeyHandler) {
+	keys := router.Group("/me/api-keys")
+	{
+		keys.GET("", handler.GetMyAPIKeys)
+		keys.POST("", handler.CreateMyAPIKey)
+		keys.DELETE("/:id", handler.DeleteMyAPIKey)
+	}
+}
@@ -0,0 +1,116 @@
+package apikeys
+
+import (
+	"context"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/roles"
+	"perretes-api/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxActiveKeys limita les claus que pot tenir un usuari alhora
+const maxActiveKeys = 20
+
+type APIKeyService interface {
+	Create(ctx context.Context, userID string, request CreateAPIKeyRequest) (CreatedAPIKeyResponse, error)
+	FindByUserID(ctx context.Context, userID string) ([]APIKey, error)
+	Revoke(ctx context.Context, userID, id string) error
+}
+
+type apiKeyService struct {
+	repo APIKeyRepository
+}
+
+func NewAPIKeyService(repo APIKeyRepository) APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+// Create genera una clau nova. Els scopes han de ser permisos que l'usuari té o
+// permisos sobre recursos propis, així una clau mai pot fer més del que pot fer
+// el seu propietari
+func (s *apiKeyService) Create(ctx context.Context, userID string, request CreateAPIKeyRequest) (CreatedAPIKeyResponse, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return CreatedAPIKeyResponse{}, ErrInvalidID
+	}
+	scopes, err := checkScopes(ctx, request.Scopes)
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return CreatedAPIKeyResponse{}, ErrInvalidExpiry
+	}
+
+	existing, err := s.repo.FindActiveByUserID(ctx, parsedUserID)
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+	if len(existing) >= maxActiveKeys {
+		return CreatedAPIKeyResponse{}, ErrTooManyKeys
+	}
+
+	key, prefix, secret, err := utils.GenerateAPIKey()
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+	created, err := s.repo.Create(ctx, APIKey{
+		ID:         uuid.New(),
+		UserID:     parsedUserID,
+		Name:       strings.TrimSpace(request.Name),
+		Prefix:     prefix,
+		SecretHash: utils.HashToken(secret),
+		Scopes:     scopes,
+		ExpiresAt:  request.ExpiresAt,
+	})
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+	return CreatedAPIKeyResponse{APIKey: created, Key: key}, nil
+}
+
+func (s *apiKeyService) FindByUserID(ctx context.Context, userID string) ([]APIKey, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return s.repo.FindActiveByUserID(ctx, parsedUserID)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID, id string) error {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	return s.repo.Revoke(ctx, parsedID, parsedUserID)
+}
+
+// checkScopes comprova que l'usuari de la petició té tots els scopes, o que
+// són permisos sobre recursos propis, i els retorna ordenats i sense repetits
+func checkScopes(ctx context.Context, requested []string) ([]string, error) {
+	caller, ok := authz.CallerFromContext(ctx)
+	if !ok {
+		return nil, authz.ErrForbidden
+	}
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if !caller.Can(scope) && !roles.IsOwnerPermission(scope) {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
@@ -2,15 +2,85 @@ package auth
 
 import (
 	"perretes-api/internal/users"
+	"strings"
+	"time"
 )
 
+// LoginRequest identifica l'usuari pel nom d'usuari o pel correu. El camp
+// username també accepta un correu
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
+	Username string `json:"username"`
+	Email    string `json:"email" binding:"omitempty,email"`
 	Password string `json:"password" binding:"required"`
 }
 
+// Login retorna l'identificador amb què s'ha d'entrar. Els correus es passen a
+// minúscules perquè el límit d'intents no es pugui saltar canviant-ne les majúscules
+func (r LoginRequest) Login() string {
+	login := strings.TrimSpace(r.Email)
+	if login == "" {
+		login = strings.TrimSpace(r.Username)
+	}
+	if strings.Contains(login, "@") {
+		login = strings.ToLower(login)
+	}
+	return login
+}
+
+// MFALoginRequest completa el login amb el token de verificació pendent i un
+// codi TOTP o de recuperació
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Tokens és el parell d'access token JWT i refresh token opac d'una sessió
+type Tokens struct {
+	AccessToken   string
+	Expire        time.Time
+	RefreshToken  string
+	RefreshExpire time.Time
+}
+
+// LoginResult és el resultat del primer pas del login. Si l'usuari té la
+// verificació en dos passos activada només porta el token de verificació pendent
+type LoginResult struct {
+	Tokens      Tokens
+	User        users.UserResponse
+	MFARequired bool
+	MFAToken    string
+	MFAExpire   time.Time
+}
+
+type MFARequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	Expire      string `json:"expire"`
+}
+
 type LoginResponse struct {
 	Token  string `json:"token"`
 	Expire string `json:"expire"`
-	User   users.User   `json:"user"`	
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpire string `json:"refresh_expire"`
+	User   users.UserResponse   `json:"user"`	
+}
+
+// ImpersonationResponse porta un token curt per consultar l'API com l'usuari.
+// No té refresh token: quan caduca se n'ha de demanar un altre
+type ImpersonationResponse struct {
+	Token  string     `json:"token"`
+	Expire string     `json:"expire"`
+	User   users.UserResponse `json:"user"`
+}
+
+type RefreshResponse struct {
+	Token         string `json:"token"`
+	Expire        string `json:"expire"`
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpire string `json:"refresh_expire"`
 }
\ No newline at end of file
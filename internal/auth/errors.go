@@ -6,4 +6,8 @@ var (
     ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrInactiveUser      = errors.New("inactive user")
+	ErrEmailNotVerified  = errors.New("email not verified")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrCannotImpersonate = errors.New("this user can't be impersonated")
+	ErrMissingSession    = errors.New("impersonation requires a session token")
 )
\ No newline at end of file
@@ -1,8 +1,15 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"perretes-api/internal/loginguard"
+	"perretes-api/internal/mfa"
+	"perretes-api/internal/sessions"
 	"perretes-api/internal/users"
+	"perretes-api/middleware"
+	"strconv"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
@@ -11,31 +18,35 @@ import (
 
 type AuthHandler struct {
     authService    AuthService
-    jwtMiddleware *jwt.GinJWTMiddleware
+    jwtMiddleware *middleware.JWTMiddleware
 }
 
-func NewAuthHandler(authService AuthService, jwtMiddleware *jwt.GinJWTMiddleware) *AuthHandler {
+func NewAuthHandler(authService AuthService, jwtMiddleware *middleware.JWTMiddleware) *AuthHandler {
     return &AuthHandler{
         authService:    authService,
         jwtMiddleware: jwtMiddleware,
     }
 }
 
-// Login processa una petició de login i retorna un token JWT
+// Login processa una petició de login i retorna un token JWT, o el token de
+// verificació pendent si l'usuari té activada la verificació en dos passos
 func (h *AuthHandler) Login(c *gin.Context) {
     var loginRequest LoginRequest
-    if err := c.ShouldBindJSON(&loginRequest); err != nil {
+    if err := c.ShouldBindJSON(&loginRequest); err != nil || loginRequest.Login() == "" {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login request"})
         return
     }
     
-    token,user, expire, err := h.authService.Login(c.Request.Context(), loginRequest)
+    result, err := h.authService.Login(c.Request.Context(), loginRequest, ClientInfo(c))
+    if RespondThrottled(c, err) {
+        return
+    }
     if err != nil {
         var statusCode int
         switch err {
         case ErrInvalidCredentials:
             statusCode = http.StatusUnauthorized
-        case users.ErrInactiveUser:
+        case users.ErrInactiveUser, ErrEmailNotVerified:
             statusCode = http.StatusForbidden
         default:
             statusCode = http.StatusInternalServerError
@@ -44,15 +55,133 @@ func (h *AuthHandler) Login(c *gin.Context) {
         c.JSON(statusCode, gin.H{"error": err.Error()})
         return
     }
-    
-    c.JSON(http.StatusOK, LoginResponse{
+    RespondLogin(c, result)
+}
+
+// LoginMFA completa el login amb el codi de l'aplicació d'autenticació o un codi de recuperació
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+    var request MFALoginRequest
+    if err := c.ShouldBindJSON(&request); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    tokens, user, err := h.authService.CompleteMFA(c.Request.Context(), request, ClientInfo(c))
+    if RespondThrottled(c, err) {
+        return
+    }
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, ErrInvalidMFAToken), errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrNotEnrolled):
+            status = http.StatusUnauthorized
+        case errors.Is(err, users.ErrInactiveUser), errors.Is(err, users.ErrUserNotFound):
+            status = http.StatusForbidden
+        }
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// Refresh canvia el refresh token per un access token nou. El refresh token
+// només es pot fer servir una vegada
+func (h *AuthHandler) Refresh(c *gin.Context) {
+    var request RefreshRequest
+    if err := c.ShouldBindJSON(&request); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    tokens, err := h.authService.Refresh(c.Request.Context(), request)
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, sessions.ErrInvalidRefresh), errors.Is(err, sessions.ErrRefreshReused), errors.Is(err, sessions.ErrSessionNotFound):
+            status = http.StatusUnauthorized
+        case errors.Is(err, users.ErrInactiveUser), errors.Is(err, users.ErrUserNotFound):
+            status = http.StatusForbidden
+        }
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, RefreshResponse{
+        Token:         tokens.AccessToken,
+        Expire:        tokens.Expire.Format(time.RFC3339),
+        RefreshToken:  tokens.RefreshToken,
+        RefreshExpire: tokens.RefreshExpire.Format(time.RFC3339),
+    })
+}
+
+// Impersonate genera un token per veure l'API com la veu un altre usuari. Queda
+// registrat a l'action log, com totes les peticions fetes amb el token
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+    actorID, _ := middleware.CurrentUserID(c)
+    sessionID, _ := jwt.ExtractClaims(c)["jti"].(string)
+
+    token, expire, user, err := h.authService.Impersonate(c.Request.Context(), actorID, sessionID, c.Param("user_id"))
+    if err != nil {
+        status := http.StatusInternalServerError
+        switch {
+        case errors.Is(err, users.ErrInvalidID):
+            status = http.StatusBadRequest
+        case errors.Is(err, users.ErrUserNotFound):
+            status = http.StatusNotFound
+        case errors.Is(err, ErrCannotImpersonate), errors.Is(err, ErrMissingSession), errors.Is(err, users.ErrInactiveUser):
+            status = http.StatusForbidden
+        }
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, ImpersonationResponse{
         Token:  token,
         Expire: expire.Format(time.RFC3339),
         User:   user,
     })
 }
 
-// RefreshToken utilitza el middleware JWT per refrescar el token
-func (h *AuthHandler) RefreshToken(c *gin.Context) {
-    h.jwtMiddleware.RefreshHandler(c)
+// RespondLogin escriu la resposta d'un login: els tokens de la sessió o el token
+// de verificació pendent si l'usuari ha de completar el segon pas
+func RespondLogin(c *gin.Context, result LoginResult) {
+    if result.MFARequired {
+        c.JSON(http.StatusOK, MFARequiredResponse{
+            MFARequired: true,
+            MFAToken:    result.MFAToken,
+            Expire:      result.MFAExpire.Format(time.RFC3339),
+        })
+        return
+    }
+    c.JSON(http.StatusOK, loginResponse(result.Tokens, result.User))
+}
+
+func loginResponse(tokens Tokens, user users.UserResponse) LoginResponse {
+    return LoginResponse{
+        Token:         tokens.AccessToken,
+        Expire:        tokens.Expire.Format(time.RFC3339),
+        RefreshToken:  tokens.RefreshToken,
+        RefreshExpire: tokens.RefreshExpire.Format(time.RFC3339),
+        User:          user,
+    }
+}
+
+// RespondThrottled respon 429 amb Retry-After si el login està bloquejat per massa intents
+func RespondThrottled(c *gin.Context, err error) bool {
+    var throttled *loginguard.ThrottledError
+    if !errors.As(err, &throttled) {
+        return false
+    }
+    c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+    c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+    return true
+}
+
+// ClientInfo és el dispositiu des del qual es fa la petició de login
+func ClientInfo(c *gin.Context) sessions.ClientInfo {
+    return sessions.ClientInfo{
+        UserAgent: c.Request.UserAgent(),
+        IPAddress: c.ClientIP(),
+    }
 }
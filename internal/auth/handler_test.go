@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"perretes-api/config"
+	"perretes-api/internal/mfa"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
+	"perretes-api/internal/testutil"
+	"perretes-api/internal/users"
+	"perretes-api/internal/users/userstest"
+	"perretes-api/middleware"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fakeRoleRepository struct{}
+
+func (fakeRoleRepository) FindByName(ctx context.Context, name string) (roles.Role, error) {
+	role := roles.Role{ID: uuid.New(), Name: name}
+	if name == roles.RoleAdmin {
+		role.Permissions = []string{roles.PermUsersWrite, roles.PermUsersImpersonate}
+	}
+	return role, nil
+}
+
+// fakeSessionService obre les sessions en memòria. Cada refresh token és l'ID de la sessió
+type fakeSessionService struct {
+	sessions.SessionService
+	mu       sync.Mutex
+	sessions map[string]sessions.Session
+}
+
+func (s *fakeSessionService) Start(ctx context.Context, userID uuid.UUID, client sessions.ClientInfo, expiresAt time.Time) (sessions.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := sessions.Session{ID: uuid.New(), UserID: userID, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	s.sessions[session.ID.String()] = session
+	return session, nil
+}
+
+func (s *fakeSessionService) IssueRefreshToken(ctx context.Context, session sessions.Session) (string, time.Time, error) {
+	return session.ID.String(), session.ExpiresAt, nil
+}
+
+func (s *fakeSessionService) RotateRefreshToken(ctx context.Context, token string) (sessions.Session, string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return sessions.Session{}, "", time.Time{}, sessions.ErrInvalidRefresh
+	}
+	return session, token, session.ExpiresAt, nil
+}
+
+type noopLoginGuard struct{}
+
+func (noopLoginGuard) Check(ctx context.Context, username, ip string) error           { return nil }
+func (noopLoginGuard) RecordFailure(ctx context.Context, username, ip, reason string) {}
+func (noopLoginGuard) RecordSuccess(ctx context.Context, username string)             {}
+
+// fakeMFAService té la verificació en dos passos activada pels usuaris indicats
+// i només accepta el codi 123456
+type fakeMFAService struct {
+	mfa.MFAService
+	enabled map[uuid.UUID]bool
+}
+
+func (s *fakeMFAService) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return s.enabled[userID], nil
+}
+
+func (s *fakeMFAService) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	if code != "123456" {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+type testSetup struct {
+	router *gin.Engine
+	laia   users.User
+	marta  users.User
+}
+
+// newTestSetup munta el servei d'autenticació real amb els usuaris en memòria:
+// les respostes es construeixen a partir dels usuaris amb el hash de la contrasenya
+func newTestSetup(t *testing.T) testSetup {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	admin := userstest.NewUser("admin", "admin@example.com", "Admin-Gos-7341", roles.RoleAdmin)
+	laia := userstest.NewUser("laia", "laia@example.com", "Laia-Gos-7341", roles.RoleCustomer)
+	marta := userstest.NewUser("marta", "marta@example.com", "Marta-Gos-7341", roles.RoleCustomer)
+
+	jwtMiddleware, err := middleware.SetupJWT(&config.Config{
+		JWTSecret:        "test-secret",
+		AccessTokenTTL:   15 * time.Minute,
+		MFATokenTTL:      5 * time.Minute,
+		ImpersonationTTL: 15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("SetupJWT: %v", err)
+	}
+	sessionService := &fakeSessionService{sessions: map[string]sessions.Session{}}
+	mfaService := &fakeMFAService{enabled: map[uuid.UUID]bool{marta.ID: true}}
+	service, err := NewAuthService(userstest.NewRepository(admin, laia, marta), fakeRoleRepository{}, sessionService,
+		noopLoginGuard{}, mfaService, jwtMiddleware, Settings{RefreshTokenTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	session, err := sessionService.Start(context.Background(), admin.ID, sessions.ClientInfo{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	handler := NewAuthHandler(service, jwtMiddleware)
+
+	router := gin.New()
+	RegisterRoutes(router.Group("/auth"), handler)
+	// Fa el paper del middleware JWT amb la sessió de l'administrador
+	protected := router.Group("", func(c *gin.Context) {
+		c.Set("JWT_PAYLOAD", jwt.MapClaims{
+			middleware.IdentityKey: admin.ID.String(),
+			"role":                 roles.RoleAdmin,
+			"permissions":          []interface{}{roles.PermUsersImpersonate},
+			"jti":                  session.ID.String(),
+		})
+		c.Set(middleware.IdentityKey, admin.ID.String())
+		c.Next()
+	})
+	RegisterAdminRoutes(protected, handler)
+
+	return testSetup{router: router, laia: laia, marta: marta}
+}
+
+func (s testSetup) post(t *testing.T, path, body string) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST %s: status = %d, want %d: %s", path, rec.Code, http.StatusOK, rec.Body.String())
+	}
+	testutil.AssertNoSecrets(t, rec.Body.Bytes())
+	var response map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	return response
+}
+
+func responseUserID(t *testing.T, response map[string]interface{}) string {
+	t.Helper()
+	user, ok := response["user"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("response has no user: %v", response)
+	}
+	id, _ := user["id"].(string)
+	return id
+}
+
+func TestLoginAndRefreshResponsesHidePassword(t *testing.T) {
+	s := newTestSetup(t)
+
+	login := s.post(t, "/auth/login", `{"username":"laia","password":"Laia-Gos-7341"}`)
+	if id := responseUserID(t, login); id != s.laia.ID.String() {
+		t.Fatalf("login user = %q, want %q", id, s.laia.ID)
+	}
+	refreshToken, _ := login["refresh_token"].(string)
+	if login["token"] == "" || refreshToken == "" {
+		t.Fatalf("login without tokens: %v", login)
+	}
+
+	refresh := s.post(t, "/auth/refresh", `{"refresh_token":"`+refreshToken+`"}`)
+	if refresh["token"] == "" {
+		t.Fatalf("refresh without token: %v", refresh)
+	}
+}
+
+func TestLoginWithMFAHidesPassword(t *testing.T) {
+	s := newTestSetup(t)
+
+	login := s.post(t, "/auth/login", `{"email":"marta@example.com","password":"Marta-Gos-7341"}`)
+	if login["mfa_required"] != true {
+		t.Fatalf("login didn't ask for the second step: %v", login)
+	}
+	if _, ok := login["user"]; ok {
+		t.Fatalf("pending login returned the user: %v", login)
+	}
+	mfaToken, _ := login["mfa_token"].(string)
+
+	completed := s.post(t, "/auth/login/mfa", `{"mfa_token":"`+mfaToken+`","code":"123456"}`)
+	if id := responseUserID(t, completed); id != s.marta.ID.String() {
+		t.Fatalf("mfa login user = %q, want %q", id, s.marta.ID)
+	}
+}
+
+func TestImpersonateResponseHidesPassword(t *testing.T) {
+	s := newTestSetup(t)
+
+	response := s.post(t, "/admin/impersonate/"+s.laia.ID.String(), "")
+	if id := responseUserID(t, response); id != s.laia.ID.String() {
+		t.Fatalf("impersonated user = %q, want %q", id, s.laia.ID)
+	}
+	if response["token"] == "" {
+		t.Fatalf("impersonation without token: %v", response)
+	}
+}
+
+// RespondLogin també el fan servir els logins amb enllaç màgic i amb proveïdors externs
+func TestRespondLoginHidesPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := users.NewUserResponse(userstest.NewUser("laia", "laia@example.com", "Laia-Gos-7341", roles.RoleCustomer))
+
+	tests := []struct {
+		name   string
+		result LoginResult
+	}{
+		{"session", LoginResult{Tokens: Tokens{AccessToken: "access", RefreshToken: "refresh"}, User: user}},
+		{"mfa required", LoginResult{MFARequired: true, MFAToken: "pending", MFAExpire: time.Now()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			RespondLogin(c, tt.result)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			testutil.AssertNoSecrets(t, rec.Body.Bytes())
+		})
+	}
+}
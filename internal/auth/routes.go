@@ -1,11 +1,22 @@
 package auth
 
 import (
-	jwt "github.com/appleboy/gin-jwt/v2"
+	"perretes-api/internal/roles"
+	"perretes-api/middleware"
+
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(router *gin.RouterGroup, handler *AuthHandler, jwtMiddleware *jwt.GinJWTMiddleware) {
+func RegisterRoutes(router *gin.RouterGroup, handler *AuthHandler) {
 	router.POST("/login", handler.Login)
-	router.GET("/refresh_token", jwtMiddleware.RefreshHandler)
-}
\ No newline at end of file
+	router.POST("/login/mfa", handler.LoginMFA)
+	router.POST("/refresh", handler.Refresh)
+}
+
+// RegisterAdminRoutes registra les rutes protegides d'administració de l'autenticació
+func RegisterAdminRoutes(router *gin.RouterGroup, handler *AuthHandler) {
+	admin := router.Group("/admin")
+	{
+		admin.POST("/impersonate/:user_id", middleware.DenyAPIKeys(), middleware.RequirePermission(roles.PermUsersImpersonate), handler.Impersonate)
+	}
+}
@@ -2,63 +2,293 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"perretes-api/internal/loginguard"
+	"perretes-api/internal/mfa"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
 	"perretes-api/internal/users"
+	"perretes-api/middleware"
 
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 
 
 type AuthService interface {
-    Login(ctx context.Context, req LoginRequest) (string, users.User, time.Time, error)
-    ValidateUser(username, password string) (users.User, error)
+    Login(ctx context.Context, req LoginRequest, client sessions.ClientInfo) (LoginResult, error)
+    LoginUser(ctx context.Context, user users.User, client sessions.ClientInfo) (LoginResult, error)
+    CompleteMFA(ctx context.Context, req MFALoginRequest, client sessions.ClientInfo) (Tokens, users.UserResponse, error)
+    Refresh(ctx context.Context, req RefreshRequest) (Tokens, error)
+    Impersonate(ctx context.Context, actorID, sessionID, targetID string) (string, time.Time, users.UserResponse, error)
+    ValidateUser(login, password string) (users.User, error)
+}
+
+// Settings agrupa les opcions configurables del servei d'autenticació
+type Settings struct {
+    RequireVerifiedEmail bool
+    RefreshTokenTTL      time.Duration
 }
 
 type authService struct {
     userRepo users.UserRepository
-    jwtMiddleware *jwt.GinJWTMiddleware
+    roleRepo roles.RoleRepository
+    sessionService sessions.SessionService
+    loginGuard loginguard.LoginGuard
+    mfaService mfa.MFAService
+    jwtMiddleware *middleware.JWTMiddleware
+    settings Settings
+    // Hash amb què es compara la contrasenya quan l'usuari no existeix
+    dummyHash []byte
 }
 
-func NewAuthService(userRepo users.UserRepository,  jwtMiddleware *jwt.GinJWTMiddleware) AuthService {
+func NewAuthService(userRepo users.UserRepository, roleRepo roles.RoleRepository, sessionService sessions.SessionService, loginGuard loginguard.LoginGuard, mfaService mfa.MFAService, jwtMiddleware *middleware.JWTMiddleware, settings Settings) (AuthService, error) {
+    dummyHash, err := bcrypt.GenerateFromPassword([]byte(uuid.NewString()), bcrypt.DefaultCost)
+    if err != nil {
+        return nil, err
+    }
     return &authService{
         userRepo: userRepo,
+        roleRepo: roleRepo,
+        sessionService: sessionService,
+        loginGuard: loginGuard,
+        mfaService: mfaService,
         jwtMiddleware: jwtMiddleware,
-    }
+        settings: settings,
+        dummyHash: dummyHash,
+    }, nil
 }
 
-// Login verifica les credencials i retorna un token JWT si són vàlides
-func (s *authService) Login(ctx context.Context, req LoginRequest) (string, users.User, time.Time, error) {
+// Login verifica les credencials i retorna un token JWT si són vàlides. Si
+// l'usuari té la verificació en dos passos activada retorna un token de
+// verificació pendent que s'ha de completar a CompleteMFA
+func (s *authService) Login(ctx context.Context, req LoginRequest, client sessions.ClientInfo) (LoginResult, error) {
+    // Comprovar que l'usuari o la IP no estiguin bloquejats per massa intents
+    login := req.Login()
+    if err := s.loginGuard.Check(ctx, login, client.IPAddress); err != nil {
+        return LoginResult{}, err
+    }
+
     // Validar les credencials
-    user, err := s.ValidateUser(req.Username, req.Password)
+    user, err := s.ValidateUser(login, req.Password)
+    if errors.Is(err, ErrInvalidCredentials) {
+        s.loginGuard.RecordFailure(ctx, login, client.IPAddress, "invalid_credentials")
+    }
     if err != nil {
-        return "", users.User{}, time.Time{}, err
+        return LoginResult{}, err
     }
-    id := user.ID.String()
-    // Generar token JWT
-    token, expire, err := s.jwtMiddleware.TokenGenerator(id)
+    s.loginGuard.RecordSuccess(ctx, login)
+
+    return s.LoginUser(ctx, user, client)
+}
+
+// LoginUser obre la sessió d'un usuari que ja s'ha autenticat, amb contrasenya o
+// per un altre camí com un proveïdor extern. Si té la verificació en dos passos
+// activada encara l'ha de completar
+func (s *authService) LoginUser(ctx context.Context, user users.User, client sessions.ClientInfo) (LoginResult, error) {
+    if !user.IsActive {
+        return LoginResult{}, users.ErrInactiveUser
+    }
+
+    mfaEnabled, err := s.mfaService.IsEnabled(ctx, user.ID)
+    if err != nil {
+        return LoginResult{}, err
+    }
+    if mfaEnabled {
+        mfaToken, expire, err := s.jwtMiddleware.TokenGenerator(middleware.MFAPending{UserID: user.ID.String()})
+        if err != nil {
+            return LoginResult{}, err
+        }
+        return LoginResult{MFARequired: true, MFAToken: mfaToken, MFAExpire: expire}, nil
+    }
+
+    tokens, err := s.startSession(ctx, user, client)
     if err != nil {
-        return "", users.User{}, time.Time{}, err
+        return LoginResult{}, err
     }
-   
-    user.Password = "" // No retornar la contrasenya en la resposta
-    return token, user,  expire, nil
+
+    return LoginResult{Tokens: tokens, User: users.NewUserResponse(user)}, nil
 }
 
-// ValidateUser verifica si les credencials són vàlides i retorna l'ID de l'usuari
-func (s *authService) ValidateUser(username, password string) (users.User, error) {
-    // Obtenir l'usuari per nom d'usuari
-    user, err := s.userRepo.FindByUsername(context.Background(), username)
+// CompleteMFA comprova el codi del segon pas i, si és correcte, obre la sessió.
+// Els codis erronis compten com a intents fallits de login
+func (s *authService) CompleteMFA(ctx context.Context, req MFALoginRequest, client sessions.ClientInfo) (Tokens, users.UserResponse, error) {
+    userID, err := s.pendingUserID(req.MFAToken)
+    if err != nil {
+        return Tokens{}, users.UserResponse{}, err
+    }
+    user, err := s.userRepo.FindByID(ctx, userID)
+    if err != nil {
+        return Tokens{}, users.UserResponse{}, err
+    }
+    if err := s.loginGuard.Check(ctx, user.Username, client.IPAddress); err != nil {
+        return Tokens{}, users.UserResponse{}, err
+    }
 
+    err = s.mfaService.Verify(ctx, user.ID, req.Code)
+    if errors.Is(err, mfa.ErrInvalidCode) {
+        s.loginGuard.RecordFailure(ctx, user.Username, client.IPAddress, "invalid_mfa_code")
+    }
     if err != nil {
-        return users.User{}, ErrUserNotFound
+        return Tokens{}, users.UserResponse{}, err
+    }
+    s.loginGuard.RecordSuccess(ctx, user.Username)
+
+    tokens, err := s.startSession(ctx, user, client)
+    if err != nil {
+        return Tokens{}, users.UserResponse{}, err
+    }
+
+    return tokens, users.NewUserResponse(user), nil
+}
+
+// pendingUserID valida el token de verificació pendent i en retorna l'usuari
+func (s *authService) pendingUserID(mfaToken string) (uuid.UUID, error) {
+    token, err := s.jwtMiddleware.ParseTokenString(mfaToken)
+    if err != nil || !token.Valid {
+        return uuid.Nil, ErrInvalidMFAToken
+    }
+    claims := jwt.ExtractClaimsFromToken(token)
+    if pending, _ := claims[middleware.MFAPendingClaim].(bool); !pending {
+        return uuid.Nil, ErrInvalidMFAToken
+    }
+    id, _ := claims["id"].(string)
+    userID, err := uuid.Parse(id)
+    if err != nil {
+        return uuid.Nil, ErrInvalidMFAToken
+    }
+    return userID, nil
+}
+
+// Refresh canvia un refresh token per un access token i un refresh token nous
+func (s *authService) Refresh(ctx context.Context, req RefreshRequest) (Tokens, error) {
+    session, refreshToken, refreshExpire, err := s.sessionService.RotateRefreshToken(ctx, req.RefreshToken)
+    if err != nil {
+        return Tokens{}, err
+    }
+    // Tornar a carregar l'usuari per agafar els canvis de rol o si s'ha desactivat
+    user, err := s.userRepo.FindByID(ctx, session.UserID)
+    if err != nil {
+        return Tokens{}, err
+    }
+    // Canviar la contrasenya revoca les sessions, però per si de cas no es
+    // renova cap sessió oberta abans del canvi
+    if user.PasswordChangedAt != nil && session.CreatedAt.Before(*user.PasswordChangedAt) {
+        return Tokens{}, sessions.ErrInvalidRefresh
+    }
+    accessToken, expire, err := s.accessToken(ctx, user, session)
+    if err != nil {
+        return Tokens{}, err
+    }
+    return Tokens{
+        AccessToken:   accessToken,
+        Expire:        expire,
+        RefreshToken:  refreshToken,
+        RefreshExpire: refreshExpire,
+    }, nil
+}
+
+// Impersonate genera un token amb la identitat de l'usuari indicat i
+// l'administrador al claim act. El token va lligat a la sessió de
+// l'administrador, de manera que tancar-la també el revoca. No es pot suplantar
+// un altre usuari que també pugui suplantar
+func (s *authService) Impersonate(ctx context.Context, actorID, sessionID, targetID string) (string, time.Time, users.UserResponse, error) {
+    if sessionID == "" {
+        return "", time.Time{}, users.UserResponse{}, ErrMissingSession
+    }
+    parsedID, err := uuid.Parse(targetID)
+    if err != nil {
+        return "", time.Time{}, users.UserResponse{}, users.ErrInvalidID
+    }
+    if targetID == actorID {
+        return "", time.Time{}, users.UserResponse{}, ErrCannotImpersonate
+    }
+    user, err := s.userRepo.FindByID(ctx, parsedID)
+    if err != nil {
+        return "", time.Time{}, users.UserResponse{}, err
     }
-    
-    // Verificar que l'usuari estigui actiu
     if !user.IsActive {
-        return users.User{}, users.ErrInactiveUser
+        return "", time.Time{}, users.UserResponse{}, users.ErrInactiveUser
+    }
+    role, err := s.roleRepo.FindByName(ctx, user.Role)
+    if err != nil {
+        return "", time.Time{}, users.UserResponse{}, err
+    }
+    for _, permission := range role.Permissions {
+        if permission == roles.PermUsersImpersonate {
+            return "", time.Time{}, users.UserResponse{}, ErrCannotImpersonate
+        }
+    }
+
+    token, expire, err := s.jwtMiddleware.TokenGenerator(middleware.Impersonation{
+        Identity: middleware.Identity{
+            ID:          user.ID.String(),
+            Role:        role.Name,
+            Permissions: role.Permissions,
+            SessionID:   sessionID,
+        },
+        ActorID: actorID,
+    })
+    if err != nil {
+        return "", time.Time{}, users.UserResponse{}, err
+    }
+
+    return token, expire, users.NewUserResponse(user), nil
+}
+
+// startSession obre una sessió nova i en genera l'access token i el primer refresh token
+func (s *authService) startSession(ctx context.Context, user users.User, client sessions.ClientInfo) (Tokens, error) {
+    session, err := s.sessionService.Start(ctx, user.ID, client, time.Now().Add(s.settings.RefreshTokenTTL))
+    if err != nil {
+        return Tokens{}, err
+    }
+    accessToken, expire, err := s.accessToken(ctx, user, session)
+    if err != nil {
+        return Tokens{}, err
+    }
+    refreshToken, refreshExpire, err := s.sessionService.IssueRefreshToken(ctx, session)
+    if err != nil {
+        return Tokens{}, err
+    }
+    return Tokens{
+        AccessToken:   accessToken,
+        Expire:        expire,
+        RefreshToken:  refreshToken,
+        RefreshExpire: refreshExpire,
+    }, nil
+}
+
+// accessToken genera el token JWT amb el rol i els permisos de l'usuari
+func (s *authService) accessToken(ctx context.Context, user users.User, session sessions.Session) (string, time.Time, error) {
+    role, err := s.roleRepo.FindByName(ctx, user.Role)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return s.jwtMiddleware.TokenGenerator(middleware.Identity{
+        ID:          user.ID.String(),
+        Role:        role.Name,
+        Permissions: role.Permissions,
+        SessionID:   session.ID.String(),
+    })
+}
+
+// ValidateUser verifica si les credencials són vàlides i retorna l'ID de l'usuari.
+// L'usuari s'identifica pel nom d'usuari o pel correu. Un usuari inexistent o
+// inactiu tarda el mateix que una contrasenya incorrecta i retorna el mateix
+// error, perquè no es pugui saber quins usuaris existeixen
+func (s *authService) ValidateUser(login, password string) (users.User, error) {
+    // Obtenir l'usuari per nom d'usuari o correu
+    user, err := s.userRepo.FindByLogin(context.Background(), login)
+    if errors.Is(err, users.ErrUserNotFound) || errors.Is(err, users.ErrInactiveUser) {
+        bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+        return users.User{}, ErrInvalidCredentials
+    }
+    if err != nil {
+        return users.User{}, err
     }
     
     // Verificar la contrasenya
@@ -66,7 +296,12 @@ func (s *authService) ValidateUser(username, password string) (users.User, error
     if err != nil {
         return users.User{}, ErrInvalidCredentials
     }
+
+    // Si està configurat, no deixar entrar fins que no hagi verificat el correu
+    if s.settings.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+        return users.User{}, ErrEmailNotVerified
+    }
     
     // Retornar l'ID de l'usuari com a identificador principal
     return user, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,68 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Caller és l'usuari autenticat que fa la petició
+type Caller struct {
+	ID          uuid.UUID
+	Role        string
+	Permissions []string
+	// APIKey indica que la petició s'ha fet amb una clau d'API, que només dona
+	// accés al que permeten els seus scopes
+	APIKey bool
+	// Scopes són els scopes de la clau d'API tal com es van crear. Permissions
+	// només hi deixa els que el rol també té, però per als recursos propis
+	// n'hi ha prou que la clau tingui l'scope
+	Scopes []string
+}
+
+type callerKey struct{}
+
+// WithCaller desa l'usuari autenticat al context
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext recupera l'usuari autenticat del context
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// Can indica si l'usuari té el permís indicat
+func (c Caller) Can(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope indica si la clau d'API de la petició té l'scope indicat
+func (c Caller) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOwner retorna ErrForbidden si l'usuari del context no és el propietari
+// del recurs i tampoc té el permís que dona accés als recursos dels altres. Amb
+// una clau d'API el propietari només hi té accés si la clau té l'scope del permís
+func CheckOwner(ctx context.Context, ownerID uuid.UUID, permission string) error {
+	caller, ok := CallerFromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+	if (caller.ID == ownerID && (!caller.APIKey || caller.HasScope(permission))) || caller.Can(permission) {
+		return nil
+	}
+	return ErrForbidden
+}
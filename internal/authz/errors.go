@@ -0,0 +1,7 @@
+package authz
+
+import "errors"
+
+var (
+	ErrForbidden = errors.New("you don't have permission to access this resource")
+)
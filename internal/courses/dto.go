@@ -17,7 +17,21 @@ type ClassRequest struct {
 	IsActive    bool   `json:"is_active"`
 }
 
+// EnrollmentRequest inscriu un usuari a un curs. dog_id és opcional i indica
+// quin dels gossos del client s'entrena
 type EnrollmentRequest struct {
 	UserID   string `json:"user_id" binding:"required"`
 	CourseID string `json:"course_id" binding:"required"`
+	DogID    string `json:"dog_id"`
+}
+
+// DiaryEntryRequest afegeix una sessió de pràctica. practiced_on té el format
+// 2006-01-02 i attachment_type és obligatori si hi ha attachment_url
+type DiaryEntryRequest struct {
+	PracticedOn     string `json:"practiced_on" binding:"required"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required,gt=0,lte=1440"`
+	Rating          int    `json:"rating" binding:"required,min=1,max=5"`
+	Notes           string `json:"notes"`
+	AttachmentURL   string `json:"attachment_url" binding:"omitempty,url,max=500"`
+	AttachmentType  string `json:"attachment_type" binding:"required_with=AttachmentURL,omitempty,oneof=photo video"`
 }
\ No newline at end of file
@@ -3,9 +3,12 @@ package courses
 import "errors"
 
 var (
-	ErrCourseNotFound     = errors.New("course not found")
-	ErrClassNotFound      = errors.New("class not found")
-	ErrEnrollmentNotFound = errors.New("enrollment not found")
-	ErrInvalidID          = errors.New("invalid ID")
-	ErrInvalidRequest     = errors.New("invalid request")
-)
\ No newline at end of file
+	ErrCourseNotFound      = errors.New("course not found")
+	ErrClassNotFound       = errors.New("class not found")
+	ErrEnrollmentNotFound  = errors.New("enrollment not found")
+	ErrInvalidID           = errors.New("invalid ID")
+	ErrInvalidRequest      = errors.New("invalid request")
+	ErrDogNotFound         = errors.New("dog not found")
+	ErrDogNotOwned         = errors.New("dog does not belong to the enrolled user")
+	ErrInvalidPracticeDate = errors.New("practiced_on must be formatted as YYYY-MM-DD and can't be in the future")
+)
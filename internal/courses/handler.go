@@ -1,7 +1,9 @@
 package courses
 
 import (
+	"errors"
 	"net/http"
+	"perretes-api/internal/authz"
 
 	"github.com/gin-gonic/gin"
 )
@@ -134,7 +136,11 @@ func(h *CourseHandler) GetCoursesByUserID(c *gin.Context) {
 	userID := c.Param("user_id")
 	courses, err := h.service.FindCoursesByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, authz.ErrForbidden) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, courses)
@@ -148,7 +154,14 @@ func (h *CourseHandler) EnrollUserToCourse(c *gin.Context){
 	}
 	enrollment, err := h.service.EnrollUserToCourse(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrDogNotOwned):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrDogNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, enrollment)
@@ -159,7 +172,16 @@ func (h *CourseHandler) MarkClassAsDone(c *gin.Context){
 	classID := c.Param("class_id")	
 	err := h.service.MarkClassAsDone(c.Request.Context(), enrollmentID, classID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, authz.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrEnrollmentNotFound), errors.Is(err, ErrClassNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusNoContent, nil)
@@ -174,3 +196,39 @@ func (h *CourseHandler) UnEnrollUserFromCourse(c *gin.Context){
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
+
+func (h *CourseHandler) CreateDiaryEntry(c *gin.Context){
+	var request DiaryEntryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := h.service.CreateDiaryEntry(c.Request.Context(), c.Param("enrollment_id"), c.Param("class_id"), request)
+	if err != nil {
+		respondDiaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *CourseHandler) GetDiaryEntries(c *gin.Context){
+	entries, err := h.service.FindDiaryEntries(c.Request.Context(), c.Param("enrollment_id"), c.Param("class_id"))
+	if err != nil {
+		respondDiaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func respondDiaryError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrInvalidPracticeDate):
+		status = http.StatusBadRequest
+	case errors.Is(err, authz.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrEnrollmentNotFound), errors.Is(err, ErrClassNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
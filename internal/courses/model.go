@@ -34,13 +34,44 @@ type UserCourse struct {
 	Description string    `json:"description" db:"description"`
 	ImageURL	string    `json:"image_url" db:"image_url"`
 	StartDate *time.Time `json:"start_date" db:"start_date"`
+	DogID    *uuid.UUID `json:"dog_id,omitempty" db:"dog_id"`
+	DogName  string    `json:"dog_name,omitempty" db:"dog_name"`
 	Classes  []UserClassProgress `json:"classes,omitempty"`
 }
 
 type UserClassProgress struct {
-	EnrollmentID uuid.UUID `json:"enrollment_id" db:"enrollment_id"`
-	ClassID      uuid.UUID `json:"class_id" db:"class_id"`
-	Title        string    `json:"title" db:"title"`
-	Description  string    `json:"description" db:"description"`
-	IsDone       bool      `json:"is_done" db:"is_done"`
+	EnrollmentID uuid.UUID    `json:"enrollment_id" db:"enrollment_id"`
+	ClassID      uuid.UUID    `json:"class_id" db:"class_id"`
+	Title        string       `json:"title" db:"title"`
+	Description  string       `json:"description" db:"description"`
+	IsDone       bool         `json:"is_done" db:"is_done"`
+	Diary        DiarySummary `json:"diary"`
+}
+
+const (
+	AttachmentPhoto = "photo"
+	AttachmentVideo = "video"
+)
+
+// DiaryEntry és una sessió de pràctica a casa d'una classe de la inscripció
+type DiaryEntry struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	EnrollmentID    uuid.UUID  `json:"enrollment_id" db:"enrollment_id"`
+	ClassID         uuid.UUID  `json:"class_id" db:"class_id"`
+	PracticedOn     time.Time  `json:"practiced_on" db:"practiced_on"`
+	DurationMinutes int        `json:"duration_minutes" db:"duration_minutes"`
+	Rating          int        `json:"rating" db:"rating"`
+	Notes           string     `json:"notes" db:"notes"`
+	AttachmentURL   string     `json:"attachment_url,omitempty" db:"attachment_url"`
+	AttachmentType  string     `json:"attachment_type,omitempty" db:"attachment_type"`
+	CreatedBy       *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// DiarySummary resumeix les sessions de pràctica d'una classe
+type DiarySummary struct {
+	Entries         int        `json:"entries"`
+	TotalMinutes    int        `json:"total_minutes"`
+	AverageRating   *float64   `json:"average_rating"`
+	LastPracticedOn *time.Time `json:"last_practiced_on"`
 }
\ No newline at end of file
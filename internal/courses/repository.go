@@ -19,9 +19,13 @@ type CourseRepository interface {
 	FindClassById(ctx context.Context, id uuid.UUID) (Class, error)
 	FindClassesByCourseId(ctx context.Context, courseID uuid.UUID) ([]Class, error)
 	FindCoursesByUserID(ctx context.Context, userID uuid.UUID) ([]UserCourse, error)
-	EnrollUserToCourse(ctx context.Context, userID, courseID uuid.UUID) (UserCourse, error)
+	EnrollUserToCourse(ctx context.Context, userID, courseID uuid.UUID, dogID *uuid.UUID) (UserCourse, error)
+	FindDogOwnerID(ctx context.Context, dogID uuid.UUID) (uuid.UUID, error)
+	FindEnrollmentUserID(ctx context.Context, enrollmentID uuid.UUID) (uuid.UUID, error)
 	MarkClassAsDone(ctx context.Context, enrollmentID, classID uuid.UUID) error
 	UnEnrollUserFromCourse(ctx context.Context, enrollmentID uuid.UUID) error
+	CreateDiaryEntry(ctx context.Context, entry DiaryEntry) (DiaryEntry, error)
+	FindDiaryEntries(ctx context.Context, enrollmentID, classID uuid.UUID) ([]DiaryEntry, error)
 }
 
 type courseRepository struct {
@@ -163,9 +167,10 @@ func (r *courseRepository) FindClassesByCourseId(ctx context.Context, courseID u
 
 func (r *courseRepository) FindCoursesByUserID(ctx context.Context, userID uuid.UUID) ([]UserCourse, error) {
     rows, err := r.db.QueryContext(ctx, `
-        SELECT ce.id as enrollment_id, c.id as course_id, c.title, c.description, c.image_url, ce.user_id, ce.start_date
+        SELECT ce.id as enrollment_id, c.id as course_id, c.title, c.description, c.image_url, ce.user_id, ce.start_date, ce.dog_id, COALESCE(d.name, '')
         FROM course_enrollments ce
         JOIN courses c ON ce.course_id = c.id
+        LEFT JOIN dogs d ON d.id = ce.dog_id
         WHERE ce.user_id = $1 AND ce.is_active = true`, userID)
     if err != nil {
         return nil, err
@@ -175,30 +180,40 @@ func (r *courseRepository) FindCoursesByUserID(ctx context.Context, userID uuid.
     var userCourses []UserCourse
     for rows.Next() {
         var uc UserCourse
-        err = rows.Scan(&uc.EnrollmentID, &uc.CourseID, &uc.Title, &uc.Description, &uc.ImageURL, &uc.UserID, &uc.StartDate)
+        err = rows.Scan(&uc.EnrollmentID, &uc.CourseID, &uc.Title, &uc.Description, &uc.ImageURL, &uc.UserID, &uc.StartDate, &uc.DogID, &uc.DogName)
         if err != nil {
             return nil, err
         }
 
-        // Fetch classes progress for this enrollment
+        // Progrés de les classes de la inscripció amb el resum del diari de
+        // pràctiques. Surten les classes fetes o amb alguna pràctica
         classRows, err := r.db.QueryContext(ctx, `
-            SELECT cp.enrollment_id, cl.id, cl.title, cl.content, cp.is_done
-            FROM class_progress cp
-            JOIN classes cl ON cp.class_id = cl.id
-            WHERE cp.enrollment_id = $1`, uc.EnrollmentID)
+            SELECT ce.id, cl.id, cl.title, COALESCE(cl.content, ''), COALESCE(cp.is_done, false),
+                COALESCE(d.entries, 0), COALESCE(d.total_minutes, 0), d.average_rating, d.last_practiced_on
+            FROM course_enrollments ce
+            JOIN classes cl ON cl.course_id = ce.course_id
+            LEFT JOIN class_progress cp ON cp.enrollment_id = ce.id AND cp.class_id = cl.id
+            LEFT JOIN (
+                SELECT class_id, count(*) AS entries, sum(duration_minutes) AS total_minutes,
+                    avg(rating)::float8 AS average_rating, max(practiced_on) AS last_practiced_on
+                FROM training_diary_entries
+                WHERE enrollment_id = $1
+                GROUP BY class_id
+            ) d ON d.class_id = cl.id
+            WHERE ce.id = $1 AND (cp.id IS NOT NULL OR d.class_id IS NOT NULL)
+            ORDER BY cl."order"`, uc.EnrollmentID)
         if err != nil {
             return nil, err
         }
         var classesProgress []UserClassProgress
         for classRows.Next() {
             var ucp UserClassProgress
-            var content string
-            err = classRows.Scan(&ucp.EnrollmentID, &ucp.ClassID, &ucp.Title, &content, &ucp.IsDone)
+            err = classRows.Scan(&ucp.EnrollmentID, &ucp.ClassID, &ucp.Title, &ucp.Description, &ucp.IsDone,
+                &ucp.Diary.Entries, &ucp.Diary.TotalMinutes, &ucp.Diary.AverageRating, &ucp.Diary.LastPracticedOn)
             if err != nil {
                 classRows.Close()
                 return nil, err
             }
-            ucp.Description = content
             classesProgress = append(classesProgress, ucp)
         }
         classRows.Close()
@@ -208,36 +223,131 @@ func (r *courseRepository) FindCoursesByUserID(ctx context.Context, userID uuid.
     return userCourses, nil
 }
 
-func (r *courseRepository) EnrollUserToCourse(ctx context.Context, userID, courseID uuid.UUID) (UserCourse, error) {
+func (r *courseRepository) EnrollUserToCourse(ctx context.Context, userID, courseID uuid.UUID, dogID *uuid.UUID) (UserCourse, error) {
     var userCourse UserCourse
+    enrollmentID := uuid.New()
     _, err := r.db.ExecContext(ctx, `
-        INSERT INTO course_enrollments(id, user_id, course_id)
-        VALUES ($1, $2, $3)`, uuid.New(), userID, courseID)
+        INSERT INTO course_enrollments(id, user_id, course_id, dog_id)
+        VALUES ($1, $2, $3, $4)`, enrollmentID, userID, courseID, dogID)
     if err != nil {
         return userCourse, err
     }
     query := `
-        SELECT ce.id as enrollment_id, c.id as course_id, c.title, c.description, c.image_url, ce.user_id, ce.start_date
+        SELECT ce.id as enrollment_id, c.id as course_id, c.title, c.description, c.image_url, ce.user_id, ce.start_date, ce.dog_id, COALESCE(d.name, '')
         FROM course_enrollments ce
         JOIN courses c ON ce.course_id = c.id
-        WHERE ce.user_id = $1 AND ce.course_id = $2 AND ce.is_active = true LIMIT 1`
-    row := r.db.QueryRowContext(ctx, query, userID, courseID)
-    err = row.Scan(&userCourse.EnrollmentID, &userCourse.CourseID, &userCourse.Title, &userCourse.Description, &userCourse.ImageURL, &userCourse.UserID, &userCourse.StartDate)
+        LEFT JOIN dogs d ON d.id = ce.dog_id
+        WHERE ce.id = $1`
+    row := r.db.QueryRowContext(ctx, query, enrollmentID)
+    err = row.Scan(&userCourse.EnrollmentID, &userCourse.CourseID, &userCourse.Title, &userCourse.Description, &userCourse.ImageURL, &userCourse.UserID, &userCourse.StartDate, &userCourse.DogID, &userCourse.DogName)
     if err != nil {
         return userCourse, err
     }
     return userCourse, nil
 }
 
+func (r *courseRepository) FindEnrollmentUserID(ctx context.Context, enrollmentID uuid.UUID) (uuid.UUID, error) {
+    var userID uuid.UUID
+    err := r.db.QueryRowContext(ctx, `
+        SELECT user_id FROM course_enrollments WHERE id = $1`, enrollmentID).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return uuid.Nil, ErrEnrollmentNotFound
+    }
+    if err != nil {
+        return uuid.Nil, err
+    }
+    return userID, nil
+}
+
+// FindDogOwnerID retorna l'usuari del client propietari d'un gos actiu
+func (r *courseRepository) FindDogOwnerID(ctx context.Context, dogID uuid.UUID) (uuid.UUID, error) {
+    var userID uuid.UUID
+    err := r.db.QueryRowContext(ctx, `
+        SELECT cu.user_id FROM dogs d
+        JOIN customers cu ON cu.id = d.customer_id
+        WHERE d.id = $1 AND d.is_active = true AND cu.user_id IS NOT NULL`, dogID).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return uuid.Nil, ErrDogNotFound
+    }
+    if err != nil {
+        return uuid.Nil, err
+    }
+    return userID, nil
+}
+
+// MarkClassAsDone marca la classe com a feta si és del curs de la inscripció.
+// Si no, retorna ErrClassNotFound
 func (r *courseRepository) MarkClassAsDone(ctx context.Context, enrollmentID, classID uuid.UUID) error {
-    _, err := r.db.ExecContext(ctx, `
-        INSERT INTO class_progress (id, enrollment_id, class_id, is_done) VALUES ($1, $2, $3, true)
+    result, err := r.db.ExecContext(ctx, `
+        INSERT INTO class_progress (id, enrollment_id, class_id, is_done)
+        SELECT $1, ce.id, cl.id, true
+        FROM course_enrollments ce
+        JOIN classes cl ON cl.course_id = ce.course_id
+        WHERE ce.id = $2 AND cl.id = $3
         ON CONFLICT (enrollment_id, class_id) DO UPDATE SET is_done = true
     `, uuid.New(), enrollmentID, classID)
-    return err
+    if err != nil {
+        return err
+    }
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        return ErrClassNotFound
+    }
+    return nil
 }
 
 func (r *courseRepository) UnEnrollUserFromCourse(ctx context.Context, enrollmentID uuid.UUID) error {
     _, err := r.db.ExecContext(ctx, `DELETE FROM course_enrollments WHERE id = $1`, enrollmentID)
     return err
 }
+
+// CreateDiaryEntry afegeix la sessió de pràctica si la classe és del curs de la
+// inscripció. Si no, retorna ErrClassNotFound
+func (r *courseRepository) CreateDiaryEntry(ctx context.Context, entry DiaryEntry) (DiaryEntry, error) {
+    err := r.db.QueryRowContext(ctx, `
+        INSERT INTO training_diary_entries
+            (id, enrollment_id, class_id, practiced_on, duration_minutes, rating, notes, attachment_url, attachment_type, created_by)
+        SELECT $1, ce.id, cl.id, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10
+        FROM course_enrollments ce
+        JOIN classes cl ON cl.course_id = ce.course_id
+        WHERE ce.id = $2 AND cl.id = $3
+        RETURNING created_at`,
+        entry.ID, entry.EnrollmentID, entry.ClassID, entry.PracticedOn, entry.DurationMinutes, entry.Rating,
+        entry.Notes, entry.AttachmentURL, entry.AttachmentType, entry.CreatedBy,
+    ).Scan(&entry.CreatedAt)
+    if err == sql.ErrNoRows {
+        return DiaryEntry{}, ErrClassNotFound
+    }
+    if err != nil {
+        return DiaryEntry{}, err
+    }
+    return entry, nil
+}
+
+func (r *courseRepository) FindDiaryEntries(ctx context.Context, enrollmentID, classID uuid.UUID) ([]DiaryEntry, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT id, enrollment_id, class_id, practiced_on, duration_minutes, rating, COALESCE(notes, ''),
+            COALESCE(attachment_url, ''), COALESCE(attachment_type, ''), created_by, created_at
+        FROM training_diary_entries
+        WHERE enrollment_id = $1 AND class_id = $2
+        ORDER BY practiced_on DESC, created_at DESC`, enrollmentID, classID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    entries := []DiaryEntry{}
+    for rows.Next() {
+        var e DiaryEntry
+        err = rows.Scan(&e.ID, &e.EnrollmentID, &e.ClassID, &e.PracticedOn, &e.DurationMinutes, &e.Rating, &e.Notes,
+            &e.AttachmentURL, &e.AttachmentType, &e.CreatedBy, &e.CreatedAt)
+        if err != nil {
+            return nil, err
+        }
+        entries = append(entries, e)
+    }
+    return entries, rows.Err()
+}
@@ -1,29 +1,42 @@
 package courses
 
-import "github.com/gin-gonic/gin"
+import (
+	"perretes-api/internal/roles"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *CourseHandler) {
+	read := middleware.RequirePermission(roles.PermCoursesRead)
+	write := middleware.RequirePermission(roles.PermCoursesWrite)
+	enroll := middleware.RequirePermission(roles.PermEnrollmentsWrite)
+
 	courses := router.Group("/courses")
 	{
 		// CRUD Cursos
-		courses.POST("", handler.CreateCourse)
-		courses.PUT("/:id", handler.UpdateCourse)
-		courses.DELETE("/:id", handler.DeleteCourse)
-		courses.GET("/:id", handler.GetCourseByID)
-		courses.GET("", handler.GetAllCourses)
+		courses.POST("", write, handler.CreateCourse)
+		courses.PUT("/:id", write, handler.UpdateCourse)
+		courses.DELETE("/:id", write, handler.DeleteCourse)
+		courses.GET("/:id", read, handler.GetCourseByID)
+		courses.GET("", read, handler.GetAllCourses)
 
 		// CRUD Classes
-		courses.POST("/classes", handler.CreateClass)
-		courses.PUT("/classes/:id", handler.UpdateClass)
-		courses.DELETE("/classes/:id", handler.DeleteClass)
-		courses.GET("/classes/:id", handler.GetClassByID)
-		courses.GET("/classes/bycourse/:course_id", handler.GetClassesByCourseID)
+		courses.POST("/classes", write, handler.CreateClass)
+		courses.PUT("/classes/:id", write, handler.UpdateClass)
+		courses.DELETE("/classes/:id", write, handler.DeleteClass)
+		courses.GET("/classes/:id", read, handler.GetClassByID)
+		courses.GET("/classes/bycourse/:course_id", read, handler.GetClassesByCourseID)
 
 		// Enrolaments i progrés
-		courses.POST("/enroll", handler.EnrollUserToCourse)
-		courses.DELETE("/enroll/:enrollment_id", handler.UnEnrollUserFromCourse)
+		courses.POST("/enroll", enroll, handler.EnrollUserToCourse)
+		courses.DELETE("/enroll/:enrollment_id", enroll, handler.UnEnrollUserFromCourse)
 		courses.POST("/enroll/:enrollment_id/classes/:class_id/done", handler.MarkClassAsDone)
 
+		// Diari de pràctiques a casa. Els permisos es comproven al servei
+		courses.GET("/enroll/:enrollment_id/classes/:class_id/diary", handler.GetDiaryEntries)
+		courses.POST("/enroll/:enrollment_id/classes/:class_id/diary", handler.CreateDiaryEntry)
+
 		// Recuperar cursos per usuari amb progrés
 		courses.GET("/user/:user_id", handler.GetCoursesByUserID)
 	}
@@ -2,6 +2,9 @@ package courses
 
 import (
 	"context"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/roles"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -21,6 +24,8 @@ type CourseService interface {
 	EnrollUserToCourse(ctx context.Context, enrollment EnrollmentRequest) (UserCourse, error)
 	MarkClassAsDone(ctx context.Context, enrollmentID, classID string) error
 	UnEnrollUserFromCourse(ctx context.Context, enrollmentID string) error	
+	CreateDiaryEntry(ctx context.Context, enrollmentID, classID string, entry DiaryEntryRequest) (DiaryEntry, error)
+	FindDiaryEntries(ctx context.Context, enrollmentID, classID string) ([]DiaryEntry, error)
 }
 
 type courseService struct {
@@ -219,6 +224,9 @@ func(s *courseService) FindCoursesByUserID(ctx context.Context, userID string) (
 	if err != nil {
 		return nil, ErrInvalidID
 	}
+	if err := authz.CheckOwner(ctx, parsedUserID, roles.PermEnrollmentsRead); err != nil {
+		return nil, err
+	}
 	courses, err := s.repo.FindCoursesByUserID(ctx, parsedUserID)
 	if err != nil {
 		return nil, err
@@ -238,7 +246,22 @@ func(s *courseService) EnrollUserToCourse(ctx context.Context, enrollment Enroll
 	if err != nil {
 		return UserCourse{}, ErrInvalidID
 	}
-	userCourse, err := s.repo.EnrollUserToCourse(ctx, userID, courseID)
+	var dogID *uuid.UUID
+	if enrollment.DogID != "" {
+		parsedDogID, err := uuid.Parse(enrollment.DogID)
+		if err != nil {
+			return UserCourse{}, ErrInvalidID
+		}
+		ownerID, err := s.repo.FindDogOwnerID(ctx, parsedDogID)
+		if err != nil {
+			return UserCourse{}, err
+		}
+		if ownerID != userID {
+			return UserCourse{}, ErrDogNotOwned
+		}
+		dogID = &parsedDogID
+	}
+	userCourse, err := s.repo.EnrollUserToCourse(ctx, userID, courseID, dogID)
 	if err != nil {
 		return UserCourse{}, err
 	}
@@ -258,6 +281,13 @@ func(s *courseService) MarkClassAsDone(ctx context.Context, enrollmentID, classI
 	if err != nil {
 		return ErrInvalidID
 	}
+	ownerID, err := s.repo.FindEnrollmentUserID(ctx, parsedEnrollmentID)
+	if err != nil {
+		return err
+	}
+	if err := authz.CheckOwner(ctx, ownerID, roles.PermEnrollmentsWrite); err != nil {
+		return err
+	}
 	err = s.repo.MarkClassAsDone(ctx, parsedEnrollmentID, parsedClassID)
 	if err != nil {
 		return err
@@ -279,4 +309,67 @@ func(s *courseService) UnEnrollUserFromCourse(ctx context.Context, enrollmentID
 	}
 	return nil
 }
-	
+
+// CreateDiaryEntry afegeix una sessió de pràctica a casa. La poden afegir el
+// propietari de la inscripció i el personal que pot gestionar inscripcions
+func(s *courseService) CreateDiaryEntry(ctx context.Context, enrollmentID, classID string, entry DiaryEntryRequest) (DiaryEntry, error){
+	parsedEnrollmentID, parsedClassID, err := s.checkEnrollmentClass(ctx, enrollmentID, classID, roles.PermEnrollmentsWrite)
+	if err != nil {
+		return DiaryEntry{}, err
+	}
+	practicedOn, err := time.Parse("2006-01-02", entry.PracticedOn)
+	if err != nil || practicedOn.After(time.Now()) {
+		return DiaryEntry{}, ErrInvalidPracticeDate
+	}
+	if (entry.AttachmentURL == "") != (entry.AttachmentType == "") {
+		return DiaryEntry{}, ErrInvalidRequest
+	}
+	newEntry := DiaryEntry{
+		ID:              uuid.New(),
+		EnrollmentID:    parsedEnrollmentID,
+		ClassID:         parsedClassID,
+		PracticedOn:     practicedOn,
+		DurationMinutes: entry.DurationMinutes,
+		Rating:          entry.Rating,
+		Notes:           entry.Notes,
+		AttachmentURL:   entry.AttachmentURL,
+		AttachmentType:  entry.AttachmentType,
+	}
+	if caller, ok := authz.CallerFromContext(ctx); ok {
+		newEntry.CreatedBy = &caller.ID
+	}
+	return s.repo.CreateDiaryEntry(ctx, newEntry)
+}
+
+// FindDiaryEntries retorna les sessions de pràctica d'una classe, de la més recent a la més antiga
+func(s *courseService) FindDiaryEntries(ctx context.Context, enrollmentID, classID string) ([]DiaryEntry, error){
+	parsedEnrollmentID, parsedClassID, err := s.checkEnrollmentClass(ctx, enrollmentID, classID, roles.PermEnrollmentsRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindDiaryEntries(ctx, parsedEnrollmentID, parsedClassID)
+}
+
+// checkEnrollmentClass valida els identificadors i comprova que l'usuari de la
+// petició és el propietari de la inscripció o té el permís indicat
+func(s *courseService) checkEnrollmentClass(ctx context.Context, enrollmentID, classID, permission string) (uuid.UUID, uuid.UUID, error){
+	if enrollmentID == "" || classID == "" {
+		return uuid.Nil, uuid.Nil, ErrInvalidRequest
+	}
+	parsedEnrollmentID, err := uuid.Parse(enrollmentID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidID
+	}
+	parsedClassID, err := uuid.Parse(classID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidID
+	}
+	ownerID, err := s.repo.FindEnrollmentUserID(ctx, parsedEnrollmentID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if err := authz.CheckOwner(ctx, ownerID, permission); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return parsedEnrollmentID, parsedClassID, nil
+}
@@ -7,4 +7,36 @@ type CustomerRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Username    string `json:"username" binding:"required"`
 	Password    string `json:"password" binding:"required"`
-}
\ No newline at end of file
+}
+
+// IdentityCustomerRequest són les dades que dona un proveïdor d'identitat extern
+// quan un client entra per primer cop. El correu ja està verificat i no hi ha telèfon
+type IdentityCustomerRequest struct {
+	Name     string
+	Surname  string
+	Email    string
+	Username string
+}
+
+
+// ListCustomersRequest són els paràmetres de GET /customers. q cerca paraules al
+// nom, cognoms, correu i telèfon. Les dates tenen el format 2006-01-02 i
+// created_to és inclosa. sort accepta name, email o created_at, amb un - al
+// davant per ordenar de manera descendent
+type ListCustomersRequest struct {
+	Cursor      string `form:"cursor"`
+	Limit       int    `form:"limit"`
+	Q           string `form:"q"`
+	IsActive    *bool  `form:"is_active"`
+	CourseID    string `form:"course_id"`
+	CreatedFrom string `form:"created_from"`
+	CreatedTo   string `form:"created_to"`
+	Sort        string `form:"sort"`
+}
+
+// CustomerListResponse porta una pàgina de clients i el total amb els filtres aplicats
+type CustomerListResponse struct {
+	Customers  []Customer `json:"customers"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
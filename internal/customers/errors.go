@@ -7,4 +7,7 @@ var (
 	ErrInvalidID        = errors.New("invalid customer ID")
 	ErrCustomerVatNumberTaken   = errors.New("customer vat number already taken")
 	ErrInvalidRequest   = errors.New("invalid request")
+	ErrInvalidSort      = errors.New("invalid sort, use name, email or created_at, optionally prefixed with -")
+	ErrInvalidLimit     = errors.New("limit must be between 1 and 100")
+	ErrInvalidFilter    = errors.New("invalid filter: course_id must be a UUID and dates must be formatted as YYYY-MM-DD")
 )
\ No newline at end of file
@@ -1,7 +1,12 @@
 package customers
 
 import (
+	"errors"
 	"net/http"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,7 +29,14 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context){
 	}
 	group, err := h.customerService.Create(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrInvalidUsername) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -71,19 +83,34 @@ func(h *CustomerHandler) GetCustomerByID(c *gin.Context){
 }
 
 func(h *CustomerHandler)GetAllCustomers(c *gin.Context){
-	customers, err := h.customerService.FindAll(c.Request.Context())
+	var request ListCustomersRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.customerService.List(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidSort), errors.Is(err, ErrInvalidLimit), errors.Is(err, ErrInvalidFilter), errors.Is(err, utils.ErrInvalidCursor):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, customers)
+	c.JSON(http.StatusOK, response)
 }
 func(h *CustomerHandler) GetCustomerByUserID(c *gin.Context){
 	userID := c.Param("user_id")
 	customer, err := h.customerService.FindCustomerByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, authz.ErrForbidden) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,163 @@
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/testutil"
+	"perretes-api/internal/users"
+	"perretes-api/internal/users/userstest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// memoryCustomerRepository guarda els clients amb l'usuari que li passa el
+// servei, com la taula customers amb el join a users
+type memoryCustomerRepository struct {
+	mu        sync.Mutex
+	customers map[uuid.UUID]Customer
+}
+
+func (r *memoryCustomerRepository) Create(ctx context.Context, customer Customer) (Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	customer.CreatedAt = time.Now()
+	r.customers[customer.ID] = customer
+	return customer, nil
+}
+
+func (r *memoryCustomerRepository) Update(ctx context.Context, customer Customer) (Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.customers[customer.ID]
+	if !ok {
+		return Customer{}, sql.ErrNoRows
+	}
+	customer.User = existing.User
+	customer.CreatedAt = existing.CreatedAt
+	r.customers[customer.ID] = customer
+	return customer, nil
+}
+
+func (r *memoryCustomerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.customers, id)
+	return nil
+}
+
+func (r *memoryCustomerRepository) FindById(ctx context.Context, id uuid.UUID) (Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	customer, ok := r.customers[id]
+	if !ok {
+		return Customer{}, sql.ErrNoRows
+	}
+	return customer, nil
+}
+
+func (r *memoryCustomerRepository) List(ctx context.Context, filter CustomerFilter) ([]Customer, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []Customer{}
+	for _, customer := range r.customers {
+		list = append(list, customer)
+	}
+	return list, len(list), nil
+}
+
+func (r *memoryCustomerRepository) FindCustomerByUserID(ctx context.Context, userID uuid.UUID) (Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, customer := range r.customers {
+		if customer.User.ID == userID {
+			return customer, nil
+		}
+	}
+	return Customer{}, sql.ErrNoRows
+}
+
+// passthroughUnitOfWork executa fn sense transacció: els repositoris són en memòria
+type passthroughUnitOfWork struct{}
+
+func (passthroughUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type noopVerifier struct{}
+
+func (noopVerifier) SendVerification(ctx context.Context, user users.User, email string) error {
+	return nil
+}
+
+// El client es crea amb els serveis reals, i l'usuari surt del repositori
+// d'usuaris amb el hash de la contrasenya
+func TestCustomerHandlerResponsesHidePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	usersService := users.NewUserService(userstest.NewRepository(), noopVerifier{}, passwordpolicy.Policy{})
+	repo := &memoryCustomerRepository{customers: map[uuid.UUID]Customer{}}
+	service := NewCustomerService(repo, usersService, passthroughUnitOfWork{})
+	handler := NewCustomerHandler(service)
+
+	created, err := service.Create(context.Background(), CustomerRequest{
+		Name:        "Laia",
+		Surname:     "Puig",
+		PhoneNumber: "600000000",
+		Email:       "laia@example.com",
+		Username:    "laia",
+		Password:    "Laia-Gos-7341",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	router := gin.New()
+	// Fa el paper de SetCaller amb un administrador autenticat
+	router.Use(func(c *gin.Context) {
+		caller := authz.Caller{ID: uuid.New(), Role: roles.RoleAdmin, Permissions: []string{roles.PermCustomersRead, roles.PermCustomersWrite}}
+		c.Request = c.Request.WithContext(authz.WithCaller(c.Request.Context(), caller))
+		c.Next()
+	})
+	router.POST("/customers", handler.CreateCustomer)
+	router.GET("/customers", handler.GetAllCustomers)
+	router.GET("/customers/:id", handler.GetCustomerByID)
+	router.PUT("/customers/:id", handler.UpdateCustomer)
+	router.GET("/customers/user/:user_id", handler.GetCustomerByUserID)
+
+	id := created.ID.String()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"create", http.MethodPost, "/customers", `{"name":"Pau","surname":"Vila","phone_number":"600000001","email":"pau@example.com","username":"pau","password":"Pau-Gos-7341"}`, http.StatusCreated},
+		{"list", http.MethodGet, "/customers", "", http.StatusOK},
+		{"find by id", http.MethodGet, "/customers/" + id, "", http.StatusOK},
+		{"update", http.MethodPut, "/customers/" + id, `{"name":"Laia","surname":"Puig","phone_number":"600000002","email":"laia@example.com","username":"laia","password":"unused"}`, http.StatusOK},
+		{"find by user", http.MethodGet, "/customers/user/" + created.User.ID.String(), "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			testutil.AssertNoSecrets(t, rec.Body.Bytes())
+		})
+	}
+}
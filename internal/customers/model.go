@@ -2,6 +2,7 @@ package customers
 
 import (
 	"perretes-api/internal/users"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,6 +13,22 @@ type Customer struct {
 	Surname       string    `json:"surname" db:"surname"`
 	PhoneNumber   string    `json:"phone_number" db:"phone_number"`
 	Email         string    `json:"email" db:"email"`		
-	User users.User `json:"user"`
+	User users.UserResponse `json:"user"`
 	IsActive	  bool      `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// CustomerFilter són els filtres i la posició d'una pàgina del llistat de clients.
+// Cada paraula de Search ha d'aparèixer al nom, cognoms, correu o telèfon
+type CustomerFilter struct {
+	Search      []string
+	IsActive    *bool
+	CourseID    *uuid.UUID
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SortField   string
+	SortDesc    bool
+	AfterKey    string
+	AfterID     uuid.UUID
+	Limit       int
 }
\ No newline at end of file
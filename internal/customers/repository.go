@@ -3,6 +3,10 @@ package customers
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"perretes-api/internal/txn"
+	"perretes-api/utils"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -12,7 +16,7 @@ type CustomerRepository interface {
 	Update(ctx context.Context, customer Customer) (Customer, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	FindById(ctx context.Context, id uuid.UUID) (Customer, error)
-	FindAll(ctx context.Context)([]Customer, error)	
+	List(ctx context.Context, filter CustomerFilter) ([]Customer, int, error)
 	FindCustomerByUserID(ctx context.Context, userID uuid.UUID) (Customer, error)
 }
 
@@ -26,13 +30,19 @@ func NewCustomerRepository(db *sql.DB) CustomerRepository {
 	}
 }
 
+// conn retorna la transacció de la unitat de treball del context, si n'hi ha
+func(r *customerRepository) conn(ctx context.Context) txn.DBTX {
+	return txn.Conn(ctx, r.db)
+}
+
 func(r *customerRepository) Create(ctx context.Context, customer Customer) (Customer, error){
-	_, err := r.db.ExecContext(ctx, `
+	err := r.conn(ctx).QueryRowContext(ctx, `
 	INSERT INTO customers(id, name, surname, phone_number, email, user_id, is_active)
 	VALUES($1, $2, $3, $4, $5, $6, $7)
+	RETURNING created_at
 	`,
 	customer.ID, customer.Name, customer.Surname, customer.PhoneNumber, customer.Email, customer.User.ID, customer.IsActive,
-	)
+	).Scan(&customer.CreatedAt)
 	if err != nil {
 		return Customer{}, err
 	}
@@ -40,7 +50,7 @@ func(r *customerRepository) Create(ctx context.Context, customer Customer) (Cust
 }
 
 func(r *customerRepository) Update(ctx context.Context, customer Customer) (Customer, error){
-	_, err := r.db.ExecContext(ctx, `
+	_, err := r.conn(ctx).ExecContext(ctx, `
 		UPDATE customers
 		set name = $1,
 		surname = $2,
@@ -56,49 +66,110 @@ func(r *customerRepository) Update(ctx context.Context, customer Customer) (Cust
 	return customer, err
 }
 func(r *customerRepository) Delete(ctx context.Context, id uuid.UUID) error{
-	_, err := r.db.ExecContext(ctx, `DELETE FROM customers WHERE id = $1`, id)
+	_, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM customers WHERE id = $1`, id)
 	return err
 }
 func(r *customerRepository) FindById(ctx context.Context, id uuid.UUID) (Customer, error){
 	var customer Customer
-	err := r.db.QueryRowContext(ctx, `SELECT id, name, surname, phone_number, email, is_active, user_id FROM customers WHERE id = $1`, id,
-).Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID)
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, name, surname, phone_number, email, is_active, user_id, created_at FROM customers WHERE id = $1`, id,
+).Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID, &customer.CreatedAt)
 if err != nil {
 	return Customer{}, err
 }
 return customer, nil
 }
 
-func(r *customerRepository) FindAll(ctx context.Context)([]Customer, error){
-	var customers []Customer
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, name, surname, phone_number, email, is_active, user_id FROM customers
-	`)
+// customerSortColumns són les columnes per les quals es pot ordenar el llistat
+var customerSortColumns = map[string]string{
+	"name":       "c.surname || ' ' || c.name",
+	"email":      "COALESCE(c.email, '')",
+	"created_at": "c.created_at",
+}
+
+// customerSearchColumn és l'expressió de l'índex trigram de la migració 021
+const customerSearchColumn = "lower(c.name || ' ' || c.surname || ' ' || COALESCE(c.email, '') || ' ' || COALESCE(c.phone_number, ''))"
+
+// List retorna una pàgina de clients i el total de clients que compleixen els
+// filtres. Es pagina per cursor (valor de la columna d'ordenació i ID) com el
+// llistat d'usuaris
+func(r *customerRepository) List(ctx context.Context, filter CustomerFilter) ([]Customer, int, error) {
+	column, ok := customerSortColumns[filter.SortField]
+	if !ok {
+		return nil, 0, ErrInvalidSort
+	}
+	direction, comparison := "ASC", ">"
+	if filter.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{"true"}
+	args := []interface{}{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, term := range filter.Search {
+		conditions = append(conditions, customerSearchColumn+" LIKE '%' || lower("+addArg(utils.EscapeLike(term))+") || '%'")
+	}
+	if filter.IsActive != nil {
+		conditions = append(conditions, "c.is_active = "+addArg(*filter.IsActive))
+	}
+	if filter.CourseID != nil {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM course_enrollments ce
+			WHERE ce.user_id = c.user_id AND ce.course_id = `+addArg(*filter.CourseID)+` AND ce.is_active = true)`)
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "c.created_at >= "+addArg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "c.created_at < "+addArg(*filter.CreatedTo))
+	}
+
+	// El total no depèn de la pàgina, es compta abans d'afegir-hi el cursor
+	var total int
+	err := r.conn(ctx).QueryRowContext(ctx,
+		"SELECT count(*) FROM customers c WHERE "+strings.Join(conditions, " AND "), args...,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting customers: %w", err)
+	}
+
+	if filter.AfterID != uuid.Nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, c.id) %s (%s, %s)", column, comparison, addArg(filter.AfterKey), addArg(filter.AfterID)))
+	}
+	query := fmt.Sprintf(`
+		SELECT c.id, c.name, c.surname, COALESCE(c.phone_number, ''), COALESCE(c.email, ''), c.is_active, c.user_id, c.created_at
+		FROM customers c
+		WHERE %s
+		ORDER BY %s %s, c.id %s
+		LIMIT %s`,
+		strings.Join(conditions, " AND "), column, direction, direction, addArg(filter.Limit))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("error getting customers: %w", err)
 	}
 	defer rows.Close()
+
+	customers := []Customer{}
 	for rows.Next() {
 		var customer Customer
-		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID); err != nil{
-			return nil, err
+		err := rows.Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID, &customer.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning customer: %w", err)
 		}
 		customers = append(customers, customer)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return customers, nil
+	return customers, total, rows.Err()
 }
 
 func(r *customerRepository) FindCustomerByUserID(ctx context.Context, userID uuid.UUID) (Customer, error){
 	var customer Customer
-	err := r.db.QueryRowContext(ctx, `SELECT id, name, surname, phone_number, email, is_active, user_id FROM customers WHERE user_id = $1`, userID,
-).Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID)
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, name, surname, phone_number, email, is_active, user_id, created_at FROM customers WHERE user_id = $1`, userID,
+).Scan(&customer.ID, &customer.Name, &customer.Surname, &customer.PhoneNumber, &customer.Email, &customer.IsActive, &customer.User.ID, &customer.CreatedAt)
 if err != nil {
 	return Customer{}, err
 }
 return customer, nil
 }
-
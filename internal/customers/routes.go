@@ -1,12 +1,17 @@
 package customers
 
-import "github.com/gin-gonic/gin"
+import (
+	"perretes-api/internal/roles"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *CustomerHandler){
-	router.POST("/customers", handler.CreateCustomer)
-	router.PUT("/customers/:id", handler.UpdateCustomer)
-	router.DELETE("/customers/:id", handler.DeleteCustomer)
-	router.GET("/customers/:id", handler.GetCustomerByID)
-	router.GET("/customers", handler.GetAllCustomers)
+	router.POST("/customers", middleware.RequirePermission(roles.PermCustomersWrite), handler.CreateCustomer)
+	router.PUT("/customers/:id", middleware.RequirePermission(roles.PermCustomersWrite), handler.UpdateCustomer)
+	router.DELETE("/customers/:id", middleware.RequirePermission(roles.PermCustomersWrite), handler.DeleteCustomer)
+	router.GET("/customers/:id", middleware.RequirePermission(roles.PermCustomersRead), handler.GetCustomerByID)
+	router.GET("/customers", middleware.RequirePermission(roles.PermCustomersRead), handler.GetAllCustomers)
 	router.GET("/customers/user/:user_id", handler.GetCustomerByUserID)
-}
\ No newline at end of file
+}
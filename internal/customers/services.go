@@ -3,27 +3,38 @@ package customers
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/txn"
 	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type CustomerService interface {
 	Create(ctx context.Context, request CustomerRequest)(Customer, error)
+	CreateFromIdentity(ctx context.Context, request IdentityCustomerRequest)(Customer, error)
 	Update(ctx context.Context,id string, request CustomerRequest)(Customer, error)
 	Delete(ctx context.Context, id string)(error)
 	FindByID(ctx context.Context, id string)(Customer, error)
-	FindAll(ctx context.Context)([]Customer, error)
+	List(ctx context.Context, request ListCustomersRequest) (CustomerListResponse, error)
 	FindCustomerByUserID(ctx context.Context, userID string) (Customer, error)	
 }
 
 type customerService struct {
 	repo CustomerRepository
 	usersService users.UserService
+	uow txn.UnitOfWork
 }
 
-func NewCustomerService(repo CustomerRepository, usersService users.UserService) CustomerService{
-	return &customerService{repo, usersService}
+// NewCustomerService crea el servei de clients. L'usuari i la fitxa de client es
+// creen dins de la mateixa unitat de treball perquè no quedin usuaris sense client
+func NewCustomerService(repo CustomerRepository, usersService users.UserService, uow txn.UnitOfWork) CustomerService{
+	return &customerService{repo, usersService, uow}
 }
 
 func(s *customerService) Create(ctx context.Context, request CustomerRequest)(Customer, error){
@@ -31,28 +42,93 @@ func(s *customerService) Create(ctx context.Context, request CustomerRequest)(Cu
 	request.Username == "" || request.Password == "" {
 		return Customer{}, ErrInvalidRequest
 	}
-	iscustomer := true
 	user := users.UserRequest {		
 		Username: request.Username,
 		Password: request.Password,		
-		IsCustomer: &iscustomer,	
+		Role: roles.RoleCustomer,
+		Email: request.Email,
 	}	
-	createdUser, err := s.usersService.Create(ctx, user)
+	var customer Customer
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		createdUser, err := s.usersService.Create(ctx, user)
+		if err != nil {
+			return err
+		}
+		customer, err = s.repo.Create(ctx, Customer{
+			ID: uuid.New(),
+			Name: request.Name,
+			Surname: request.Surname,
+			PhoneNumber: request.PhoneNumber,
+			Email: request.Email,
+			User: createdUser,
+			IsActive: true,
+		})
+		return err
+	})
 	if err != nil {
 		return Customer{}, err
 	}
+	return customer, nil
+}
 
-	customer := Customer{
-		ID: uuid.New(),
-		Name: request.Name,
-		Surname: request.Surname,
-		PhoneNumber: request.PhoneNumber,
-		Email: request.Email,
-		User: createdUser,
-		IsActive: true,
+// CreateFromIdentity crea el client i el seu usuari la primera vegada que algú entra
+// amb un proveïdor extern. La contrasenya és aleatòria: si en vol una, la pot
+// restablir amb el correu. Si el nom d'usuari ja existeix s'hi afegeix un sufix
+func(s *customerService) CreateFromIdentity(ctx context.Context, request IdentityCustomerRequest)(Customer, error){
+	if request.Name == "" || request.Email == "" || request.Username == "" {
+		return Customer{}, ErrInvalidRequest
+	}
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return Customer{}, err
 	}
+	// El sufix fa que la contrasenya aleatòria compleixi qualsevol política de caràcters
+	password := token + "aA1!"
+
+	var customer Customer
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		username := request.Username
+		var createdUser users.UserResponse
+		var err error
+		for attempt := 0; ; attempt++ {
+			// Cada intent va en un savepoint: si l'insert falla, la transacció continua sent vàlida
+			err = s.uow.Do(ctx, func(ctx context.Context) error {
+				createdUser, err = s.usersService.Create(ctx, users.UserRequest{
+					Username: username,
+					Password: password,
+					Role: roles.RoleCustomer,
+					Email: request.Email,
+					EmailVerified: true,
+				})
+				return err
+			})
+			if !errors.Is(err, users.ErrUsernameTaken) || attempt == 4 {
+				break
+			}
+			suffix, err := utils.GenerateToken()
+			if err != nil {
+				return err
+			}
+			username = request.Username + "-" + strings.ToLower(suffix[:6])
+		}
+		if err != nil {
+			return err
+		}
 
-	return s.repo.Create(ctx, customer)
+		customer, err = s.repo.Create(ctx, Customer{
+			ID: uuid.New(),
+			Name: request.Name,
+			Surname: request.Surname,
+			Email: request.Email,
+			User: createdUser,
+			IsActive: true,
+		})
+		return err
+	})
+	if err != nil {
+		return Customer{}, err
+	}
+	return customer, nil
 }
 
 func(s *customerService) Update(ctx context.Context, id string, request CustomerRequest)(Customer, error){
@@ -64,16 +140,37 @@ func(s *customerService) Update(ctx context.Context, id string, request Customer
 	request.Username == "" || request.Password == "" {
 		return Customer{}, ErrInvalidRequest
 	}
-	customer := Customer{
-		ID: customerID,
-		Name: request.Name,
-		Surname: request.Surname,
-		PhoneNumber: request.PhoneNumber,
-		Email: request.Email,
-		IsActive: true,
+	existing, err := s.repo.FindById(ctx, customerID)
+	if err == sql.ErrNoRows {
+		return Customer{}, ErrCustomerNotFound
 	}
-
-	return s.repo.Update(ctx, customer)	
+	if err != nil {
+		return Customer{}, err
+	}
+	// El correu de l'usuari i el del client es canvien junts perquè no quedin diferents
+	var customer Customer
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// El correu també serveix per entrar: es canvia primer a l'usuari, que comprova que no estigui agafat
+		if existing.User.ID != uuid.Nil && !strings.EqualFold(existing.Email, request.Email) {
+			if _, err := s.usersService.ChangeEmail(ctx, existing.User.ID.String(), request.Email); err != nil {
+				return err
+			}
+		}
+		var err error
+		customer, err = s.repo.Update(ctx, Customer{
+			ID: customerID,
+			Name: request.Name,
+			Surname: request.Surname,
+			PhoneNumber: request.PhoneNumber,
+			Email: request.Email,
+			IsActive: true,
+		})
+		return err
+	})
+	if err != nil {
+		return Customer{}, err
+	}
+	return customer, nil
 }
 
 func(s *customerService) Delete(ctx context.Context, id string)(error){
@@ -90,8 +187,104 @@ func(s *customerService) FindByID(ctx context.Context, id string)(Customer, erro
 	}
 	return s.repo.FindById(ctx, customerID)
 }
-func(s *customerService) FindAll(ctx context.Context)([]Customer, error){
-	return s.repo.FindAll(ctx)
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+	maxSearchTerms   = 5
+)
+
+// List retorna una pàgina de clients amb el total. El cursor porta l'ordre amb
+// què s'ha generat, com el del llistat d'usuaris
+func(s *customerService) List(ctx context.Context, request ListCustomersRequest) (CustomerListResponse, error){
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 1 || limit > maxListLimit {
+		return CustomerListResponse{}, ErrInvalidLimit
+	}
+
+	sort := request.Sort
+	if sort == "" {
+		sort = "name"
+	}
+	filter := CustomerFilter{
+		IsActive:  request.IsActive,
+		SortField: strings.TrimPrefix(sort, "-"),
+		SortDesc:  strings.HasPrefix(sort, "-"),
+		// Se'n demana un de més per saber si hi ha pàgina següent
+		Limit: limit + 1,
+	}
+	if _, ok := customerSortColumns[filter.SortField]; !ok {
+		return CustomerListResponse{}, ErrInvalidSort
+	}
+	filter.Search = strings.Fields(request.Q)
+	if len(filter.Search) > maxSearchTerms {
+		filter.Search = filter.Search[:maxSearchTerms]
+	}
+	if request.CourseID != "" {
+		courseID, err := uuid.Parse(request.CourseID)
+		if err != nil {
+			return CustomerListResponse{}, ErrInvalidFilter
+		}
+		filter.CourseID = &courseID
+	}
+	if request.CreatedFrom != "" {
+		from, err := time.Parse("2006-01-02", request.CreatedFrom)
+		if err != nil {
+			return CustomerListResponse{}, ErrInvalidFilter
+		}
+		filter.CreatedFrom = &from
+	}
+	if request.CreatedTo != "" {
+		to, err := time.Parse("2006-01-02", request.CreatedTo)
+		if err != nil {
+			return CustomerListResponse{}, ErrInvalidFilter
+		}
+		// created_to és inclosa: es filtra fins a l'inici del dia següent
+		to = to.AddDate(0, 0, 1)
+		filter.CreatedTo = &to
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return CustomerListResponse{}, ErrInvalidFilter
+	}
+
+	if request.Cursor != "" {
+		values, err := utils.DecodeCursor(request.Cursor, 3)
+		if err != nil || values[0] != sort {
+			return CustomerListResponse{}, utils.ErrInvalidCursor
+		}
+		filter.AfterKey = values[1]
+		filter.AfterID, err = uuid.Parse(values[2])
+		if err != nil {
+			return CustomerListResponse{}, utils.ErrInvalidCursor
+		}
+	}
+
+	found, total, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return CustomerListResponse{}, err
+	}
+
+	response := CustomerListResponse{Customers: []Customer{}, Total: total}
+	for i, customer := range found {
+		if i == limit {
+			last := found[limit-1]
+			var key string
+			switch filter.SortField {
+			case "name":
+				key = last.Surname + " " + last.Name
+			case "email":
+				key = last.Email
+			case "created_at":
+				key = last.CreatedAt.Format(time.RFC3339Nano)
+			}
+			response.NextCursor = utils.EncodeCursor(sort, key, last.ID.String())
+			break
+		}
+		response.Customers = append(response.Customers, customer)
+	}
+	return response, nil
 }
 
 func(s *customerService) FindCustomerByUserID(ctx context.Context, userID string) (Customer, error){
@@ -99,6 +292,9 @@ func(s *customerService) FindCustomerByUserID(ctx context.Context, userID string
 	if err != nil {
 		return Customer{}, ErrInvalidID
 	}
+	if err := authz.CheckOwner(ctx, userUUID, roles.PermCustomersRead); err != nil {
+		return Customer{}, err
+	}
 
 	customer, err := s.repo.FindCustomerByUserID(ctx, userUUID)
 	if err != nil && err != sql.ErrNoRows {
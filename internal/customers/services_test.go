@@ -0,0 +1,103 @@
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/testutil"
+	"perretes-api/internal/txn"
+	"perretes-api/internal/users"
+	"strings"
+	"testing"
+)
+
+// countingVerifier compta els correus de verificació enviats
+type countingVerifier struct{ sent int }
+
+func (v *countingVerifier) SendVerification(ctx context.Context, user users.User, email string) error {
+	v.sent++
+	return nil
+}
+
+type fakeCustomerRepository struct {
+	CustomerRepository
+	db     *sql.DB
+	insert string
+}
+
+func (r *fakeCustomerRepository) Create(ctx context.Context, customer Customer) (Customer, error) {
+	if _, err := txn.Conn(ctx, r.db).ExecContext(ctx, r.insert); err != nil {
+		return Customer{}, err
+	}
+	return customer, nil
+}
+
+// newTestService fa servir el repositori i el servei d'usuaris reals sobre la
+// base de dades falsa, de manera que les sentències dels usuaris queden apuntades
+func newTestService(t *testing.T, customerInsert string) (CustomerService, *testutil.Recorder, *countingVerifier) {
+	t.Helper()
+	db, r := testutil.NewDB(t)
+	verifier := &countingVerifier{}
+	usersService := users.NewUserService(users.NewUserRepository(db), verifier, passwordpolicy.Policy{HistorySize: 3})
+	repo := &fakeCustomerRepository{db: db, insert: customerInsert}
+	return NewCustomerService(repo, usersService, txn.NewUnitOfWork(db)), r, verifier
+}
+
+// assertStatements comprova que cada sentència comença com s'espera
+func assertStatements(t *testing.T, r *testutil.Recorder, want ...string) {
+	t.Helper()
+	got := r.Log()
+	if len(got) != len(want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+var createRequest = CustomerRequest{
+	Name:        "Laia",
+	Surname:     "Puig",
+	PhoneNumber: "600000000",
+	Email:       "laia@example.com",
+	Username:    "laia",
+	Password:    "Laia-Gos-7341",
+}
+
+func TestCreateCommitsUserAndCustomerTogether(t *testing.T) {
+	service, r, verifier := newTestService(t, "INSERT INTO customers")
+
+	if _, err := service.Create(context.Background(), createRequest); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	assertStatements(t, r,
+		"BEGIN",
+		"SELECT u.id, u.username", "SELECT u.id, u.username",
+		"INSERT INTO users",
+		"SAVEPOINT sp_1", "INSERT INTO password_history", "DELETE FROM password_history", "RELEASE SAVEPOINT sp_1",
+		"INSERT INTO customers",
+		"COMMIT")
+	if verifier.sent != 1 {
+		t.Fatalf("verification emails sent = %d, want 1", verifier.sent)
+	}
+}
+
+func TestCreateRollsBackUserWhenCustomerInsertFails(t *testing.T) {
+	service, r, verifier := newTestService(t, "INSERT INTO customers fail")
+
+	if _, err := service.Create(context.Background(), createRequest); err == nil {
+		t.Fatal("Create: expected an error")
+	}
+	assertStatements(t, r,
+		"BEGIN",
+		"SELECT u.id, u.username", "SELECT u.id, u.username",
+		"INSERT INTO users",
+		"SAVEPOINT sp_1", "INSERT INTO password_history", "DELETE FROM password_history", "RELEASE SAVEPOINT sp_1",
+		"INSERT INTO customers fail",
+		"ROLLBACK")
+	if verifier.sent != 0 {
+		t.Fatalf("verification emails sent = %d after a rollback, want 0", verifier.sent)
+	}
+}
@@ -0,0 +1,14 @@
+package dogs
+
+// DogRequest crea o modifica un gos. birth_date té el format 2006-01-02
+type DogRequest struct {
+	Name            string   `json:"name" binding:"required,max=100"`
+	Breed           string   `json:"breed" binding:"max=100"`
+	BirthDate       string   `json:"birth_date"`
+	Sex             string   `json:"sex" binding:"omitempty,oneof=male female"`
+	WeightKg        *float64 `json:"weight_kg" binding:"omitempty,gt=0,lt=1000"`
+	Neutered        bool     `json:"neutered"`
+	MicrochipNumber string   `json:"microchip_number" binding:"max=20"`
+	PhotoURL        string   `json:"photo_url" binding:"omitempty,url,max=500"`
+	BehaviourNotes  string   `json:"behaviour_notes"`
+}
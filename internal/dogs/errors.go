@@ -0,0 +1,11 @@
+package dogs
+
+import "errors"
+
+var (
+	ErrDogNotFound    = errors.New("dog not found")
+	ErrInvalidID      = errors.New("invalid dog ID")
+	ErrInvalidBirth   = errors.New("birth_date must be a past date formatted as YYYY-MM-DD")
+	ErrInvalidName    = errors.New("name can't contain control characters")
+	ErrMicrochipTaken = errors.New("microchip number already registered")
+)
@@ -0,0 +1,88 @@
+package dogs
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/customers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DogHandler struct {
+	service DogService
+}
+
+func NewDogHandler(service DogService) *DogHandler {
+	return &DogHandler{service: service}
+}
+
+func (h *DogHandler) GetDogs(c *gin.Context) {
+	dogs, err := h.service.FindByCustomerID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dogs)
+}
+
+func (h *DogHandler) GetDog(c *gin.Context) {
+	dog, err := h.service.FindByID(c.Request.Context(), c.Param("id"), c.Param("dog_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dog)
+}
+
+func (h *DogHandler) CreateDog(c *gin.Context) {
+	var request DogRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dog, err := h.service.Create(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, dog)
+}
+
+func (h *DogHandler) UpdateDog(c *gin.Context) {
+	var request DogRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dog, err := h.service.Update(c.Request.Context(), c.Param("id"), c.Param("dog_id"), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dog)
+}
+
+func (h *DogHandler) DeleteDog(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), c.Param("dog_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, customers.ErrInvalidID), errors.Is(err, ErrInvalidBirth),
+		errors.Is(err, ErrInvalidName):
+		status = http.StatusBadRequest
+	case errors.Is(err, authz.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrDogNotFound), errors.Is(err, customers.ErrCustomerNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrMicrochipTaken):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,29 @@
+package dogs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SexMale   = "male"
+	SexFemale = "female"
+)
+
+// Dog és un gos d'un client. És el que realment s'entrena als cursos
+type Dog struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	CustomerID      uuid.UUID  `json:"customer_id" db:"customer_id"`
+	Name            string     `json:"name" db:"name"`
+	Breed           string     `json:"breed" db:"breed"`
+	BirthDate       *time.Time `json:"birth_date" db:"birth_date"`
+	Sex             string     `json:"sex" db:"sex"`
+	WeightKg        *float64   `json:"weight_kg" db:"weight_kg"`
+	Neutered        bool       `json:"neutered" db:"neutered"`
+	MicrochipNumber string     `json:"microchip_number" db:"microchip_number"`
+	PhotoURL        string     `json:"photo_url" db:"photo_url"`
+	BehaviourNotes  string     `json:"behaviour_notes" db:"behaviour_notes"`
+	IsActive        bool       `json:"is_active" db:"is_active"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,155 @@
+package dogs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type DogRepository interface {
+	Create(ctx context.Context, dog Dog) (Dog, error)
+	Update(ctx context.Context, dog Dog) (Dog, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByID(ctx context.Context, id uuid.UUID) (Dog, error)
+	FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Dog, error)
+	FindByMicrochip(ctx context.Context, microchipNumber string) (Dog, error)
+}
+
+type dogRepository struct {
+	db *sql.DB
+}
+
+func NewDogRepository(db *sql.DB) DogRepository {
+	return &dogRepository{db: db}
+}
+
+const dogColumns = `id, customer_id, name, COALESCE(breed, ''), birth_date, COALESCE(sex, ''), weight_kg, neutered,
+	COALESCE(microchip_number, ''), COALESCE(photo_url, ''), COALESCE(behaviour_notes, ''), is_active, created_at`
+
+// microchipIndex és l'índex únic dels xips dels gossos actius
+const microchipIndex = "idx_dogs_microchip_number"
+
+// isMicrochipTaken indica si l'error és la violació de l'índex únic del xip. El
+// servei ho comprova abans, però dues peticions alhora poden passar la comprovació
+func isMicrochipTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == microchipIndex
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDog(row scanner) (Dog, error) {
+	var d Dog
+	err := row.Scan(&d.ID, &d.CustomerID, &d.Name, &d.Breed, &d.BirthDate, &d.Sex, &d.WeightKg, &d.Neutered,
+		&d.MicrochipNumber, &d.PhotoURL, &d.BehaviourNotes, &d.IsActive, &d.CreatedAt)
+	return d, err
+}
+
+func (r *dogRepository) Create(ctx context.Context, dog Dog) (Dog, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO dogs (id, customer_id, name, breed, birth_date, sex, weight_kg, neutered, microchip_number, photo_url, behaviour_notes, is_active)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), true)
+		RETURNING is_active, created_at`,
+		dog.ID, dog.CustomerID, dog.Name, dog.Breed, dog.BirthDate, dog.Sex, dog.WeightKg, dog.Neutered,
+		dog.MicrochipNumber, dog.PhotoURL, dog.BehaviourNotes,
+	).Scan(&dog.IsActive, &dog.CreatedAt)
+	if isMicrochipTaken(err) {
+		return Dog{}, ErrMicrochipTaken
+	}
+	if err != nil {
+		return Dog{}, fmt.Errorf("error inserting dog: %w", err)
+	}
+	return dog, nil
+}
+
+func (r *dogRepository) Update(ctx context.Context, dog Dog) (Dog, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE dogs
+		SET name = $1, breed = NULLIF($2, ''), birth_date = $3, sex = NULLIF($4, ''), weight_kg = $5, neutered = $6,
+			microchip_number = NULLIF($7, ''), photo_url = NULLIF($8, ''), behaviour_notes = NULLIF($9, '')
+		WHERE id = $10 AND is_active = true`,
+		dog.Name, dog.Breed, dog.BirthDate, dog.Sex, dog.WeightKg, dog.Neutered,
+		dog.MicrochipNumber, dog.PhotoURL, dog.BehaviourNotes, dog.ID,
+	)
+	if isMicrochipTaken(err) {
+		return Dog{}, ErrMicrochipTaken
+	}
+	if err != nil {
+		return Dog{}, fmt.Errorf("error updating dog: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return Dog{}, err
+	}
+	if affected == 0 {
+		return Dog{}, ErrDogNotFound
+	}
+	return r.FindByID(ctx, dog.ID)
+}
+
+// Delete desactiva el gos. Es manté perquè les inscripcions on ha participat
+// continuïn apuntant-hi
+func (r *dogRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE dogs SET is_active = false WHERE id = $1 AND is_active = true`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting dog: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrDogNotFound
+	}
+	return nil
+}
+
+func (r *dogRepository) FindByID(ctx context.Context, id uuid.UUID) (Dog, error) {
+	dog, err := scanDog(r.db.QueryRowContext(ctx, `
+		SELECT `+dogColumns+` FROM dogs WHERE id = $1 AND is_active = true`, id))
+	if err == sql.ErrNoRows {
+		return Dog{}, ErrDogNotFound
+	} else if err != nil {
+		return Dog{}, fmt.Errorf("error getting dog: %w", err)
+	}
+	return dog, nil
+}
+
+func (r *dogRepository) FindByCustomerID(ctx context.Context, customerID uuid.UUID) ([]Dog, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+dogColumns+` FROM dogs
+		WHERE customer_id = $1 AND is_active = true
+		ORDER BY name`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dogs: %w", err)
+	}
+	defer rows.Close()
+
+	dogs := []Dog{}
+	for rows.Next() {
+		dog, err := scanDog(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning dog: %w", err)
+		}
+		dogs = append(dogs, dog)
+	}
+	return dogs, rows.Err()
+}
+
+func (r *dogRepository) FindByMicrochip(ctx context.Context, microchipNumber string) (Dog, error) {
+	dog, err := scanDog(r.db.QueryRowContext(ctx, `
+		SELECT `+dogColumns+` FROM dogs WHERE microchip_number = $1 AND is_active = true`, microchipNumber))
+	if err == sql.ErrNoRows {
+		return Dog{}, ErrDogNotFound
+	} else if err != nil {
+		return Dog{}, fmt.Errorf("error getting dog: %w", err)
+	}
+	return dog, nil
+}
@@ -0,0 +1,16 @@
+package dogs
+
+import "github.com/gin-gonic/gin"
+
+// RegisterRoutes registra els gossos dins del client. Els permisos es comproven
+// al servei perquè el client també pot gestionar els seus
+func RegisterRoutes(router *gin.RouterGroup, handler *DogHandler) {
+	dogs := router.Group("/customers/:id/dogs")
+	{
+		dogs.GET("", handler.GetDogs)
+		dogs.POST("", handler.CreateDog)
+		dogs.GET("/:dog_id", handler.GetDog)
+		dogs.PUT("/:dog_id", handler.UpdateDog)
+		dogs.DELETE("/:dog_id", handler.DeleteDog)
+	}
+}
@@ -0,0 +1,157 @@
+package dogs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/roles"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// DogService gestiona els gossos d'un client. El personal amb permís sobre els
+// clients hi té accés i el client, als seus
+type DogService interface {
+	Create(ctx context.Context, customerID string, request DogRequest) (Dog, error)
+	Update(ctx context.Context, customerID, id string, request DogRequest) (Dog, error)
+	Delete(ctx context.Context, customerID, id string) error
+	FindByID(ctx context.Context, customerID, id string) (Dog, error)
+	FindByCustomerID(ctx context.Context, customerID string) ([]Dog, error)
+}
+
+type dogService struct {
+	repo         DogRepository
+	customerRepo customers.CustomerRepository
+}
+
+func NewDogService(repo DogRepository, customerRepo customers.CustomerRepository) DogService {
+	return &dogService{repo: repo, customerRepo: customerRepo}
+}
+
+func (s *dogService) Create(ctx context.Context, customerID string, request DogRequest) (Dog, error) {
+	customer, err := s.checkCustomer(ctx, customerID, roles.PermCustomersWrite)
+	if err != nil {
+		return Dog{}, err
+	}
+	dog, err := s.fromRequest(ctx, uuid.New(), request)
+	if err != nil {
+		return Dog{}, err
+	}
+	dog.CustomerID = customer
+	return s.repo.Create(ctx, dog)
+}
+
+func (s *dogService) Update(ctx context.Context, customerID, id string, request DogRequest) (Dog, error) {
+	existing, err := s.findDog(ctx, customerID, id, roles.PermCustomersWrite)
+	if err != nil {
+		return Dog{}, err
+	}
+	dog, err := s.fromRequest(ctx, existing.ID, request)
+	if err != nil {
+		return Dog{}, err
+	}
+	dog.CustomerID = existing.CustomerID
+	return s.repo.Update(ctx, dog)
+}
+
+func (s *dogService) Delete(ctx context.Context, customerID, id string) error {
+	dog, err := s.findDog(ctx, customerID, id, roles.PermCustomersWrite)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, dog.ID)
+}
+
+func (s *dogService) FindByID(ctx context.Context, customerID, id string) (Dog, error) {
+	return s.findDog(ctx, customerID, id, roles.PermCustomersRead)
+}
+
+func (s *dogService) FindByCustomerID(ctx context.Context, customerID string) ([]Dog, error) {
+	customer, err := s.checkCustomer(ctx, customerID, roles.PermCustomersRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByCustomerID(ctx, customer)
+}
+
+// checkCustomer comprova que el client existeix i que l'usuari de la petició
+// n'és el propietari o té el permís indicat
+func (s *dogService) checkCustomer(ctx context.Context, customerID, permission string) (uuid.UUID, error) {
+	parsedID, err := uuid.Parse(customerID)
+	if err != nil {
+		return uuid.Nil, customers.ErrInvalidID
+	}
+	customer, err := s.customerRepo.FindById(ctx, parsedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, customers.ErrCustomerNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := authz.CheckOwner(ctx, customer.User.ID, permission); err != nil {
+		return uuid.Nil, err
+	}
+	return customer.ID, nil
+}
+
+// findDog retorna el gos si és del client indicat
+func (s *dogService) findDog(ctx context.Context, customerID, id, permission string) (Dog, error) {
+	customer, err := s.checkCustomer(ctx, customerID, permission)
+	if err != nil {
+		return Dog{}, err
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Dog{}, ErrInvalidID
+	}
+	dog, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return Dog{}, err
+	}
+	if dog.CustomerID != customer {
+		return Dog{}, ErrDogNotFound
+	}
+	return dog, nil
+}
+
+func (s *dogService) fromRequest(ctx context.Context, id uuid.UUID, request DogRequest) (Dog, error) {
+	dog := Dog{
+		ID:              id,
+		Name:            strings.TrimSpace(request.Name),
+		Breed:           strings.TrimSpace(request.Breed),
+		Sex:             request.Sex,
+		WeightKg:        request.WeightKg,
+		Neutered:        request.Neutered,
+		MicrochipNumber: strings.TrimSpace(request.MicrochipNumber),
+		PhotoURL:        request.PhotoURL,
+		BehaviourNotes:  request.BehaviourNotes,
+	}
+	// El nom surt a l'assumpte dels correus, on un salt de línia podria afegir capçaleres
+	if strings.IndexFunc(dog.Name, unicode.IsControl) >= 0 {
+		return Dog{}, ErrInvalidName
+	}
+	if request.BirthDate != "" {
+		birthDate, err := time.Parse("2006-01-02", request.BirthDate)
+		if err != nil || birthDate.After(time.Now()) {
+			return Dog{}, ErrInvalidBirth
+		}
+		dog.BirthDate = &birthDate
+	}
+
+	// Un mateix xip no pot estar registrat a dos gossos
+	if dog.MicrochipNumber != "" {
+		existing, err := s.repo.FindByMicrochip(ctx, dog.MicrochipNumber)
+		if err == nil && existing.ID != id {
+			return Dog{}, ErrMicrochipTaken
+		}
+		if err != nil && !errors.Is(err, ErrDogNotFound) {
+			return Dog{}, err
+		}
+	}
+	return dog, nil
+}
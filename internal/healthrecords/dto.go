@@ -0,0 +1,15 @@
+package healthrecords
+
+// HealthRecordRequest crea o modifica un registre. Les dates tenen el format 2006-01-02
+type HealthRecordRequest struct {
+	Type           string `json:"type" binding:"required,oneof=vaccination deworming"`
+	Name           string `json:"name" binding:"required,max=100"`
+	AdministeredAt string `json:"administered_at" binding:"required"`
+	ExpiresAt      string `json:"expires_at" binding:"required"`
+	Veterinarian   string `json:"veterinarian" binding:"max=200"`
+	Notes          string `json:"notes"`
+}
+
+type ExpiringRequest struct {
+	Days int `form:"days"`
+}
@@ -0,0 +1,10 @@
+package healthrecords
+
+import "errors"
+
+var (
+	ErrRecordNotFound = errors.New("health record not found")
+	ErrInvalidID      = errors.New("invalid health record ID")
+	ErrInvalidDate    = errors.New("dates must be formatted as YYYY-MM-DD and expires_at must be after administered_at")
+	ErrInvalidDays    = errors.New("days must be between 1 and 365")
+)
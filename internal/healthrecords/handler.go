@@ -0,0 +1,92 @@
+package healthrecords
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/dogs"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthRecordHandler struct {
+	service HealthRecordService
+}
+
+func NewHealthRecordHandler(service HealthRecordService) *HealthRecordHandler {
+	return &HealthRecordHandler{service: service}
+}
+
+func (h *HealthRecordHandler) GetRecords(c *gin.Context) {
+	records, err := h.service.FindByDogID(c.Request.Context(), c.Param("id"), c.Param("dog_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+func (h *HealthRecordHandler) CreateRecord(c *gin.Context) {
+	var request HealthRecordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	record, err := h.service.Create(c.Request.Context(), c.Param("id"), c.Param("dog_id"), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
+
+func (h *HealthRecordHandler) UpdateRecord(c *gin.Context) {
+	var request HealthRecordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	record, err := h.service.Update(c.Request.Context(), c.Param("id"), c.Param("dog_id"), c.Param("record_id"), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func (h *HealthRecordHandler) DeleteRecord(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), c.Param("dog_id"), c.Param("record_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *HealthRecordHandler) GetExpiring(c *gin.Context) {
+	var request ExpiringRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := h.service.FindExpiring(c.Request.Context(), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidDate), errors.Is(err, ErrInvalidDays),
+		errors.Is(err, dogs.ErrInvalidID), errors.Is(err, customers.ErrInvalidID):
+		status = http.StatusBadRequest
+	case errors.Is(err, authz.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrRecordNotFound), errors.Is(err, dogs.ErrDogNotFound), errors.Is(err, customers.ErrCustomerNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,37 @@
+package healthrecords
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeVaccination = "vaccination"
+	TypeDeworming   = "deworming"
+)
+
+// HealthRecord és una vacuna o desparasitació d'un gos. Per fer classes de grup
+// cal que la darrera de cada tipus i nom no hagi caducat
+type HealthRecord struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	DogID          uuid.UUID  `json:"dog_id" db:"dog_id"`
+	Type           string     `json:"type" db:"type"`
+	Name           string     `json:"name" db:"name"`
+	AdministeredAt time.Time  `json:"administered_at" db:"administered_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	Veterinarian   string     `json:"veterinarian" db:"veterinarian"`
+	Notes          string     `json:"notes" db:"notes"`
+	ReminderSentAt *time.Time `json:"reminder_sent_at" db:"reminder_sent_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ExpiringRecord és un registre que caduca aviat amb el gos i el client a qui
+// s'ha d'avisar
+type ExpiringRecord struct {
+	HealthRecord
+	DogName       string    `json:"dog_name"`
+	CustomerID    uuid.UUID `json:"customer_id"`
+	CustomerName  string    `json:"customer_name"`
+	CustomerEmail string    `json:"customer_email"`
+}
@@ -0,0 +1,85 @@
+package healthrecords
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"perretes-api/internal/mailer"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var recordTypeNames = map[string]string{
+	TypeVaccination: "la vacuna",
+	TypeDeworming:   "la desparasitació",
+}
+
+// ReminderJob avisa per correu els clients quan una vacuna o desparasitació dels
+// seus gossos està a punt de caducar. Cada registre s'avisa una sola vegada
+type ReminderJob struct {
+	repo     HealthRecordRepository
+	mailer   mailer.Mailer
+	days     int
+	interval time.Duration
+}
+
+func NewReminderJob(repo HealthRecordRepository, mailer mailer.Mailer, days int, interval time.Duration) *ReminderJob {
+	return &ReminderJob{repo: repo, mailer: mailer, days: days, interval: interval}
+}
+
+// Run envia els avisos pendents cada interval fins que es cancel·la el context.
+// Amb un interval de 0 els avisos queden desactivats
+func (j *ReminderJob) Run(ctx context.Context) {
+	if j.interval <= 0 || j.days <= 0 {
+		return
+	}
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.runOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *ReminderJob) runOnce(ctx context.Context) {
+	records, err := j.repo.ClaimReminders(ctx, j.days)
+	if err != nil {
+		log.Printf("Error getting health reminders: %v", err)
+		return
+	}
+	for _, record := range records {
+		if err := j.send(ctx, record); err != nil {
+			log.Printf("Error sending health reminder %s: %v", record.ID, err)
+			// Es torna a deixar pendent perquè s'intenti a la següent execució
+			if err := j.repo.ResetReminder(ctx, record.ID); err != nil {
+				log.Printf("Error resetting health reminder %s: %v", record.ID, err)
+			}
+		}
+	}
+}
+
+func (j *ReminderJob) send(ctx context.Context, record ExpiringRecord) error {
+	what := fmt.Sprintf("%s %s", recordTypeNames[record.Type], record.Name)
+	return j.mailer.Send(ctx, mailer.Message{
+		To:      []string{record.CustomerEmail},
+		Subject: subjectText(fmt.Sprintf("%s ha de renovar %s", record.DogName, what)),
+		Body: fmt.Sprintf("Hola %s,\n\nEt recordem que %s de %s caduca el %s. Recorda renovar-la abans perquè pugui continuar venint a les classes.\n",
+			record.CustomerName, what, record.DogName, record.ExpiresAt.Format("02/01/2006")),
+	})
+}
+
+// subjectText canvia els caràcters de control per espais. Els noms els escriuen
+// els usuaris i a l'assumpte no hi pot haver salts de línia
+func subjectText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
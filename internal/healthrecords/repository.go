@@ -0,0 +1,205 @@
+package healthrecords
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type HealthRecordRepository interface {
+	Create(ctx context.Context, record HealthRecord) (HealthRecord, error)
+	Update(ctx context.Context, record HealthRecord) (HealthRecord, error)
+	Delete(ctx context.Context, id, dogID uuid.UUID) error
+	FindByID(ctx context.Context, id, dogID uuid.UUID) (HealthRecord, error)
+	FindByDogID(ctx context.Context, dogID uuid.UUID) ([]HealthRecord, error)
+	FindExpiring(ctx context.Context, days int) ([]ExpiringRecord, error)
+	ClaimReminders(ctx context.Context, days int) ([]ExpiringRecord, error)
+	ResetReminder(ctx context.Context, id uuid.UUID) error
+}
+
+type healthRecordRepository struct {
+	db *sql.DB
+}
+
+func NewHealthRecordRepository(db *sql.DB) HealthRecordRepository {
+	return &healthRecordRepository{db: db}
+}
+
+const recordColumns = `r.id, r.dog_id, r.type, r.name, r.administered_at, r.expires_at,
+	COALESCE(r.veterinarian, ''), COALESCE(r.notes, ''), r.reminder_sent_at, r.created_at`
+
+// currentRecord exclou els registres que ja s'han renovat amb un de posterior
+const currentRecord = `NOT EXISTS (
+	SELECT 1 FROM dog_health_records n
+	WHERE n.dog_id = r.dog_id AND n.type = r.type AND lower(n.name) = lower(r.name) AND n.expires_at > r.expires_at)`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner, extra ...interface{}) (HealthRecord, error) {
+	var r HealthRecord
+	dest := append([]interface{}{&r.ID, &r.DogID, &r.Type, &r.Name, &r.AdministeredAt, &r.ExpiresAt,
+		&r.Veterinarian, &r.Notes, &r.ReminderSentAt, &r.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+	return r, err
+}
+
+func (r *healthRecordRepository) Create(ctx context.Context, record HealthRecord) (HealthRecord, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO dog_health_records (id, dog_id, type, name, administered_at, expires_at, veterinarian, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING created_at`,
+		record.ID, record.DogID, record.Type, record.Name, record.AdministeredAt, record.ExpiresAt,
+		record.Veterinarian, record.Notes,
+	).Scan(&record.CreatedAt)
+	if err != nil {
+		return HealthRecord{}, fmt.Errorf("error inserting health record: %w", err)
+	}
+	return record, nil
+}
+
+// Update modifica el registre. Si canvia la caducitat s'haurà de tornar a avisar
+func (r *healthRecordRepository) Update(ctx context.Context, record HealthRecord) (HealthRecord, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE dog_health_records
+		SET type = $1, name = $2, administered_at = $3, veterinarian = NULLIF($5, ''), notes = NULLIF($6, ''),
+			reminder_sent_at = CASE WHEN expires_at = $4 THEN reminder_sent_at END,
+			expires_at = $4
+		WHERE id = $7 AND dog_id = $8`,
+		record.Type, record.Name, record.AdministeredAt, record.ExpiresAt, record.Veterinarian, record.Notes,
+		record.ID, record.DogID,
+	)
+	if err != nil {
+		return HealthRecord{}, fmt.Errorf("error updating health record: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	if affected == 0 {
+		return HealthRecord{}, ErrRecordNotFound
+	}
+	return r.FindByID(ctx, record.ID, record.DogID)
+}
+
+func (r *healthRecordRepository) Delete(ctx context.Context, id, dogID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM dog_health_records WHERE id = $1 AND dog_id = $2`, id, dogID)
+	if err != nil {
+		return fmt.Errorf("error deleting health record: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *healthRecordRepository) FindByID(ctx context.Context, id, dogID uuid.UUID) (HealthRecord, error) {
+	record, err := scanRecord(r.db.QueryRowContext(ctx, `
+		SELECT `+recordColumns+` FROM dog_health_records r WHERE r.id = $1 AND r.dog_id = $2`, id, dogID))
+	if err == sql.ErrNoRows {
+		return HealthRecord{}, ErrRecordNotFound
+	} else if err != nil {
+		return HealthRecord{}, fmt.Errorf("error getting health record: %w", err)
+	}
+	return record, nil
+}
+
+func (r *healthRecordRepository) FindByDogID(ctx context.Context, dogID uuid.UUID) ([]HealthRecord, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+recordColumns+` FROM dog_health_records r
+		WHERE r.dog_id = $1
+		ORDER BY r.expires_at DESC`, dogID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting health records: %w", err)
+	}
+	defer rows.Close()
+
+	records := []HealthRecord{}
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning health record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// FindExpiring retorna els registres vigents de gossos actius que caduquen en
+// els propers dies, del que caduca abans al que caduca més tard
+func (r *healthRecordRepository) FindExpiring(ctx context.Context, days int) ([]ExpiringRecord, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+recordColumns+`, d.name, c.id, c.name || ' ' || c.surname, COALESCE(c.email, '')
+		FROM dog_health_records r
+		JOIN dogs d ON d.id = r.dog_id
+		JOIN customers c ON c.id = d.customer_id
+		WHERE d.is_active = true AND c.is_active = true
+			AND r.expires_at BETWEEN current_date AND current_date + $1::int
+			AND `+currentRecord+`
+		ORDER BY r.expires_at, d.name`, days)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expiring health records: %w", err)
+	}
+	return scanExpiring(rows)
+}
+
+// ClaimReminders marca com a avisats els registres que caduquen en els propers
+// dies i encara no s'han avisat, i els retorna. Marcar-los abans d'enviar el
+// correu evita que dues instàncies avisin del mateix registre
+func (r *healthRecordRepository) ClaimReminders(ctx context.Context, days int) ([]ExpiringRecord, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT r.id FROM dog_health_records r
+			JOIN dogs d ON d.id = r.dog_id
+			JOIN customers c ON c.id = d.customer_id
+			WHERE r.reminder_sent_at IS NULL AND d.is_active = true AND c.is_active = true AND c.email <> ''
+				AND r.expires_at BETWEEN current_date AND current_date + $1::int
+				AND `+currentRecord+`
+			FOR UPDATE OF r SKIP LOCKED
+		), claimed AS (
+			UPDATE dog_health_records h SET reminder_sent_at = now()
+			FROM due WHERE h.id = due.id
+			RETURNING h.*
+		)
+		SELECT `+recordColumns+`, d.name, c.id, c.name || ' ' || c.surname, c.email
+		FROM claimed r
+		JOIN dogs d ON d.id = r.dog_id
+		JOIN customers c ON c.id = d.customer_id`, days)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming health reminders: %w", err)
+	}
+	return scanExpiring(rows)
+}
+
+// ResetReminder torna a deixar el registre pendent d'avisar si el correu ha fallat
+func (r *healthRecordRepository) ResetReminder(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE dog_health_records SET reminder_sent_at = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error resetting health reminder: %w", err)
+	}
+	return nil
+}
+
+func scanExpiring(rows *sql.Rows) ([]ExpiringRecord, error) {
+	defer rows.Close()
+	records := []ExpiringRecord{}
+	for rows.Next() {
+		var e ExpiringRecord
+		record, err := scanRecord(rows, &e.DogName, &e.CustomerID, &e.CustomerName, &e.CustomerEmail)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning health record: %w", err)
+		}
+		e.HealthRecord = record
+		records = append(records, e)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,23 @@
+package healthrecords
+
+import (
+	"perretes-api/internal/roles"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes registra els registres de salut dins del gos. El client pot
+// consultar els dels seus gossos, però només el personal els pot modificar
+func RegisterRoutes(router *gin.RouterGroup, handler *HealthRecordHandler) {
+	router.GET("/dogs/health-records/expiring", middleware.RequirePermission(roles.PermCustomersRead), handler.GetExpiring)
+
+	write := middleware.RequirePermission(roles.PermCustomersWrite)
+	records := router.Group("/customers/:id/dogs/:dog_id/health-records")
+	{
+		records.GET("", handler.GetRecords)
+		records.POST("", write, handler.CreateRecord)
+		records.PUT("/:record_id", write, handler.UpdateRecord)
+		records.DELETE("/:record_id", write, handler.DeleteRecord)
+	}
+}
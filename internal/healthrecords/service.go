@@ -0,0 +1,120 @@
+package healthrecords
+
+import (
+	"context"
+	"perretes-api/internal/dogs"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultExpiringDays = 30
+	maxExpiringDays     = 365
+)
+
+// HealthRecordService gestiona les vacunes i desparasitacions dels gossos. L'accés
+// al gos es comprova amb el servei de gossos, de manera que el client pot veure
+// els registres dels seus
+type HealthRecordService interface {
+	Create(ctx context.Context, customerID, dogID string, request HealthRecordRequest) (HealthRecord, error)
+	Update(ctx context.Context, customerID, dogID, id string, request HealthRecordRequest) (HealthRecord, error)
+	Delete(ctx context.Context, customerID, dogID, id string) error
+	FindByDogID(ctx context.Context, customerID, dogID string) ([]HealthRecord, error)
+	FindExpiring(ctx context.Context, request ExpiringRequest) ([]ExpiringRecord, error)
+}
+
+type healthRecordService struct {
+	repo       HealthRecordRepository
+	dogService dogs.DogService
+}
+
+func NewHealthRecordService(repo HealthRecordRepository, dogService dogs.DogService) HealthRecordService {
+	return &healthRecordService{repo: repo, dogService: dogService}
+}
+
+func (s *healthRecordService) Create(ctx context.Context, customerID, dogID string, request HealthRecordRequest) (HealthRecord, error) {
+	dog, err := s.dogService.FindByID(ctx, customerID, dogID)
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	record, err := fromRequest(uuid.New(), dog.ID, request)
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	return s.repo.Create(ctx, record)
+}
+
+func (s *healthRecordService) Update(ctx context.Context, customerID, dogID, id string, request HealthRecordRequest) (HealthRecord, error) {
+	existing, err := s.findRecord(ctx, customerID, dogID, id)
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	record, err := fromRequest(existing.ID, existing.DogID, request)
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	return s.repo.Update(ctx, record)
+}
+
+func (s *healthRecordService) Delete(ctx context.Context, customerID, dogID, id string) error {
+	record, err := s.findRecord(ctx, customerID, dogID, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, record.ID, record.DogID)
+}
+
+func (s *healthRecordService) FindByDogID(ctx context.Context, customerID, dogID string) ([]HealthRecord, error) {
+	dog, err := s.dogService.FindByID(ctx, customerID, dogID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByDogID(ctx, dog.ID)
+}
+
+func (s *healthRecordService) FindExpiring(ctx context.Context, request ExpiringRequest) ([]ExpiringRecord, error) {
+	days := request.Days
+	if days == 0 {
+		days = defaultExpiringDays
+	}
+	if days < 1 || days > maxExpiringDays {
+		return nil, ErrInvalidDays
+	}
+	return s.repo.FindExpiring(ctx, days)
+}
+
+// findRecord retorna el registre si és del gos i el client indicats
+func (s *healthRecordService) findRecord(ctx context.Context, customerID, dogID, id string) (HealthRecord, error) {
+	dog, err := s.dogService.FindByID(ctx, customerID, dogID)
+	if err != nil {
+		return HealthRecord{}, err
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return HealthRecord{}, ErrInvalidID
+	}
+	return s.repo.FindByID(ctx, parsedID, dog.ID)
+}
+
+func fromRequest(id, dogID uuid.UUID, request HealthRecordRequest) (HealthRecord, error) {
+	administeredAt, err := time.Parse("2006-01-02", request.AdministeredAt)
+	if err != nil {
+		return HealthRecord{}, ErrInvalidDate
+	}
+	expiresAt, err := time.Parse("2006-01-02", request.ExpiresAt)
+	if err != nil || !expiresAt.After(administeredAt) {
+		return HealthRecord{}, ErrInvalidDate
+	}
+	return HealthRecord{
+		ID:             id,
+		DogID:          dogID,
+		Type:           request.Type,
+		Name:           strings.TrimSpace(request.Name),
+		AdministeredAt: administeredAt,
+		ExpiresAt:      expiresAt,
+		Veterinarian:   strings.TrimSpace(request.Veterinarian),
+		Notes:          request.Notes,
+	}, nil
+}
@@ -0,0 +1,7 @@
+package jwks
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router gin.IRouter, handler *JWKSHandler) {
+	router.GET("/.well-known/jwks.json", handler.GetJWKS)
+}
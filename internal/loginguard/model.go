@@ -0,0 +1,35 @@
+package loginguard
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ScopeUsername = "username"
+	ScopeIP       = "ip"
+	// Les peticions d'enllaços d'accés es compten a part dels logins
+	ScopeMagicLinkEmail = "magic_link_email"
+	ScopeMagicLinkIP    = "magic_link_ip"
+)
+
+// ActionFailedLogin és el tipus amb què es registren els logins fallits a action_logs
+const ActionFailedLogin = "LOGIN_FAILED"
+
+// Policy defineix quan comencen els retards i quan es bloqueja una clau
+type Policy struct {
+	// A partir d'aquests errors cada intent fallit dobla el temps d'espera
+	DelayAfter int
+	// Amb aquests errors la clau queda bloquejada durant Lockout
+	LockAfter int
+	Lockout   time.Duration
+}
+
+// ThrottledError indica que cal esperar abans de tornar a intentar el login
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
@@ -0,0 +1,83 @@
+package loginguard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type ThrottleRepository interface {
+	BlockedUntil(ctx context.Context, usernameScope, username, ipScope, ip string) (*time.Time, error)
+	RecordFailure(ctx context.Context, scope, key string, window time.Duration) (int, error)
+	Block(ctx context.Context, scope, key string, until time.Time, resetFailures bool) error
+	Reset(ctx context.Context, scope, key string) error
+}
+
+type throttleRepository struct {
+	db *sql.DB
+}
+
+func NewThrottleRepository(db *sql.DB) ThrottleRepository {
+	return &throttleRepository{db: db}
+}
+
+// BlockedUntil retorna fins quan està bloquejat l'usuari o la IP, el que sigui més tard
+func (r *throttleRepository) BlockedUntil(ctx context.Context, usernameScope, username, ipScope, ip string) (*time.Time, error) {
+	var until *time.Time
+	err := r.db.QueryRowContext(ctx, `
+		SELECT max(blocked_until)
+		FROM login_throttles
+		WHERE blocked_until > now()
+		AND ((scope = $1 AND key = $2) OR (scope = $3 AND key = $4))`,
+		usernameScope, username, ipScope, ip,
+	).Scan(&until)
+	if err != nil {
+		return nil, fmt.Errorf("error getting login throttle: %w", err)
+	}
+	return until, nil
+}
+
+// RecordFailure suma un error a la clau i retorna el total. Els errors més antics
+// que la finestra no compten
+func (r *throttleRepository) RecordFailure(ctx context.Context, scope, key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, now())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < now() - make_interval(secs => $3) THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = now()
+		RETURNING failures`,
+		scope, key, window.Seconds(),
+	).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("error recording login failure: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *throttleRepository) Block(ctx context.Context, scope, key string, until time.Time, resetFailures bool) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE login_throttles
+		SET blocked_until = $3,
+			failures = CASE WHEN $4 THEN 0 ELSE failures END
+		WHERE scope = $1 AND key = $2`,
+		scope, key, until, resetFailures,
+	)
+	if err != nil {
+		return fmt.Errorf("error blocking login: %w", err)
+	}
+	return nil
+}
+
+func (r *throttleRepository) Reset(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return fmt.Errorf("error resetting login throttle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package loginguard
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActionLogger desa una entrada a action_logs
+type ActionLogger interface {
+	SaveActionLog(userID uuid.UUID, actionType, metadata, timezone string, performedAt time.Time) error
+}
+
+// LoginGuard limita els intents de login fallits per nom d'usuari i per IP
+type LoginGuard interface {
+	Check(ctx context.Context, username, ip string) error
+	RecordFailure(ctx context.Context, username, ip, reason string)
+	RecordSuccess(ctx context.Context, username string)
+}
+
+type loginGuard struct {
+	repo           ThrottleRepository
+	actionLogger   ActionLogger
+	usernamePolicy Policy
+	ipPolicy       Policy
+	usernameScope  string
+	ipScope        string
+}
+
+func NewLoginGuard(repo ThrottleRepository, actionLogger ActionLogger, usernamePolicy, ipPolicy Policy) LoginGuard {
+	return &loginGuard{
+		repo:           repo,
+		actionLogger:   actionLogger,
+		usernamePolicy: usernamePolicy,
+		ipPolicy:       ipPolicy,
+		usernameScope:  ScopeUsername,
+		ipScope:        ScopeIP,
+	}
+}
+
+// NewMagicLinkGuard limita les peticions d'enllaços d'accés per correu i per IP.
+// Cada petició compta com un intent, amb comptadors separats dels del login. Les
+// peticions ja queden a action_logs, així que no es registren a part
+func NewMagicLinkGuard(repo ThrottleRepository, emailPolicy, ipPolicy Policy) LoginGuard {
+	return &loginGuard{
+		repo:           repo,
+		usernamePolicy: emailPolicy,
+		ipPolicy:       ipPolicy,
+		usernameScope:  ScopeMagicLinkEmail,
+		ipScope:        ScopeMagicLinkIP,
+	}
+}
+
+// Check retorna un ThrottledError si l'usuari o la IP han d'esperar. Els intents
+// rebutjats es registren però no allarguen el bloqueig
+func (g *loginGuard) Check(ctx context.Context, username, ip string) error {
+	until, err := g.repo.BlockedUntil(ctx, g.usernameScope, normalize(username), g.ipScope, ip)
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		g.logFailure(username, ip, "throttled")
+		return &ThrottledError{RetryAfter: time.Until(*until)}
+	}
+	return nil
+}
+
+// RecordFailure compta l'error per a l'usuari i la IP, aplica el retard o el
+// bloqueig que toqui i ho registra a action_logs. Els errors només es registren
+// al log perquè no han d'impedir respondre al client
+func (g *loginGuard) RecordFailure(ctx context.Context, username, ip, reason string) {
+	g.fail(ctx, g.usernameScope, normalize(username), g.usernamePolicy)
+	if ip != "" {
+		g.fail(ctx, g.ipScope, ip, g.ipPolicy)
+	}
+	g.logFailure(username, ip, reason)
+}
+
+func (g *loginGuard) logFailure(username, ip, reason string) {
+	if g.actionLogger == nil {
+		return
+	}
+	metadata, err := json.Marshal(map[string]string{
+		"username": username,
+		"ip":       ip,
+		"reason":   reason,
+	})
+	if err != nil {
+		metadata = []byte("{}")
+	}
+	if err := g.actionLogger.SaveActionLog(uuid.Nil, ActionFailedLogin, string(metadata), "", time.Now()); err != nil {
+		log.Printf("Error saving action log: %v", err)
+	}
+}
+
+// RecordSuccess esborra els errors de l'usuari. Els de la IP es mantenen perquè
+// un compte vàlid no serveixi per desbloquejar-la
+func (g *loginGuard) RecordSuccess(ctx context.Context, username string) {
+	if err := g.repo.Reset(ctx, g.usernameScope, normalize(username)); err != nil {
+		log.Printf("Error resetting login throttle: %v", err)
+	}
+}
+
+func (g *loginGuard) fail(ctx context.Context, scope, key string, policy Policy) {
+	failures, err := g.repo.RecordFailure(ctx, scope, key, policy.Lockout)
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+		return
+	}
+
+	var until time.Time
+	lock := failures >= policy.LockAfter
+	switch {
+	case lock:
+		until = time.Now().Add(policy.Lockout)
+	case failures > policy.DelayAfter:
+		until = time.Now().Add(failureDelay(failures, policy))
+	default:
+		return
+	}
+
+	if err := g.repo.Block(ctx, scope, key, until, lock); err != nil {
+		log.Printf("Error blocking login: %v", err)
+	}
+}
+
+// failureDelay és l'espera després de l'error número failures quan ja s'ha
+// passat de DelayAfter: 1s, 2s, 4s, ... sense passar del temps de bloqueig. Amb
+// desplaçaments grans la durada desbordaria, per això a partir de 2^30 segons
+// ja es fa servir el temps de bloqueig
+func failureDelay(failures int, policy Policy) time.Duration {
+	shift := failures - policy.DelayAfter - 1
+	if shift < 0 {
+		shift = 0
+	}
+	delay := policy.Lockout
+	if shift <= 30 {
+		delay = time.Second << uint(shift)
+	}
+	if delay <= 0 || delay > policy.Lockout {
+		delay = policy.Lockout
+	}
+	return delay
+}
+
+func normalize(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
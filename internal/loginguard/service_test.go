@@ -0,0 +1,37 @@
+package loginguard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureDelayStaysWithinLockout(t *testing.T) {
+	policies := []Policy{
+		{DelayAfter: 3, LockAfter: 10, Lockout: 15 * time.Minute},
+		{DelayAfter: 20, LockAfter: 100, Lockout: 15 * time.Minute},
+		{DelayAfter: 0, LockAfter: 1000, Lockout: 24 * time.Hour},
+	}
+	for _, policy := range policies {
+		previous := time.Duration(0)
+		for failures := policy.DelayAfter + 1; failures <= policy.LockAfter+100; failures++ {
+			delay := failureDelay(failures, policy)
+			if delay <= 0 || delay > policy.Lockout {
+				t.Fatalf("policy %+v: delay after %d failures = %s, want within (0, %s]", policy, failures, delay, policy.Lockout)
+			}
+			if delay < previous {
+				t.Fatalf("policy %+v: delay after %d failures = %s, shorter than the previous %s", policy, failures, delay, previous)
+			}
+			previous = delay
+		}
+	}
+}
+
+func TestFailureDelayDoubles(t *testing.T) {
+	policy := Policy{DelayAfter: 3, LockAfter: 10, Lockout: 15 * time.Minute}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for i, expected := range want {
+		if got := failureDelay(policy.DelayAfter+1+i, policy); got != expected {
+			t.Fatalf("delay after %d failures = %s, want %s", policy.DelayAfter+1+i, got, expected)
+		}
+	}
+}
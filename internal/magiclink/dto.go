@@ -0,0 +1,9 @@
+package magiclink
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ExchangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
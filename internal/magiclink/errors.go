@@ -0,0 +1,8 @@
+package magiclink
+
+import "errors"
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired login link")
+	ErrInvalidRequest = errors.New("invalid request")
+)
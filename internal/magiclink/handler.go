@@ -0,0 +1,66 @@
+package magiclink
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"perretes-api/internal/auth"
+	"perretes-api/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkHandler struct {
+	service MagicLinkService
+}
+
+func NewMagicLinkHandler(service MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{service: service}
+}
+
+func (h *MagicLinkHandler) RequestLink(c *gin.Context) {
+	var request MagicLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.RequestLink(c.Request.Context(), request, c.ClientIP())
+	if errors.Is(err, ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if auth.RespondThrottled(c, err) {
+		return
+	}
+	// Qualsevol altre error només es pot produir si el correu existeix
+	if err != nil {
+		log.Printf("Error requesting magic link: %v", err)
+	}
+
+	// Sempre la mateixa resposta, existeixi o no el correu
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email exists, a login link has been sent"})
+}
+
+// Exchange canvia el token de l'enllaç per una sessió, amb la mateixa resposta que el login
+func (h *MagicLinkHandler) Exchange(c *gin.Context) {
+	var request ExchangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Exchange(c.Request.Context(), request, auth.ClientInfo(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrInvalidRequest):
+			status = http.StatusUnauthorized
+		case errors.Is(err, users.ErrInactiveUser), errors.Is(err, users.ErrUserNotFound):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	auth.RespondLogin(c, result)
+}
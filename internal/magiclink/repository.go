@@ -0,0 +1,68 @@
+package magiclink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type LoginTokenRepository interface {
+	Create(ctx context.Context, token LoginToken) (LoginToken, error)
+	InvalidateByUserID(ctx context.Context, userID uuid.UUID) error
+	Consume(ctx context.Context, tokenHash string) (uuid.UUID, error)
+}
+
+type loginTokenRepository struct {
+	db *sql.DB
+}
+
+func NewLoginTokenRepository(db *sql.DB) LoginTokenRepository {
+	return &loginTokenRepository{db: db}
+}
+
+func (r *loginTokenRepository) Create(ctx context.Context, token LoginToken) (LoginToken, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO magic_link_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		token.ID, token.UserID, token.TokenHash, token.ExpiresAt,
+	)
+	if err != nil {
+		return LoginToken{}, fmt.Errorf("error inserting login token: %w", err)
+	}
+	return token, nil
+}
+
+// InvalidateByUserID marca com a utilitzats els enllaços pendents de l'usuari
+func (r *loginTokenRepository) InvalidateByUserID(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE magic_link_tokens
+		SET used_at = now()
+		WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error invalidating login tokens: %w", err)
+	}
+	return nil
+}
+
+// Consume marca el token com a utilitzat i retorna l'usuari al qual pertany.
+// Es fa en una sola sentència perquè el mateix enllaç no es pugui fer servir dues vegades
+func (r *loginTokenRepository) Consume(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE magic_link_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`,
+		tokenHash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrInvalidToken
+	} else if err != nil {
+		return uuid.Nil, fmt.Errorf("error consuming login token: %w", err)
+	}
+	return userID, nil
+}
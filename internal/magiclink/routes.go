@@ -0,0 +1,11 @@
+package magiclink
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *MagicLinkHandler) {
+	magicLink := router.Group("/magic-link")
+	{
+		magicLink.POST("", handler.RequestLink)
+		magicLink.POST("/exchange", handler.Exchange)
+	}
+}
@@ -0,0 +1,140 @@
+package magiclink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"perretes-api/internal/auth"
+	"perretes-api/internal/loginguard"
+	"perretes-api/internal/mailer"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MagicLinkService interface {
+	RequestLink(ctx context.Context, request MagicLinkRequest, ip string) error
+	Exchange(ctx context.Context, request ExchangeRequest, client sessions.ClientInfo) (auth.LoginResult, error)
+}
+
+type magicLinkService struct {
+	repo        LoginTokenRepository
+	userRepo    users.UserRepository
+	authService auth.AuthService
+	guard       loginguard.LoginGuard
+	mailer      mailer.Mailer
+	loginURL    string
+	ttl         time.Duration
+}
+
+// NewMagicLinkService crea el servei d'enllaços d'accés. guard limita les
+// peticions per correu i per IP perquè no es pugui omplir la bústia de ningú
+func NewMagicLinkService(repo LoginTokenRepository, userRepo users.UserRepository, authService auth.AuthService, guard loginguard.LoginGuard, mailer mailer.Mailer, loginURL string, ttl time.Duration) MagicLinkService {
+	return &magicLinkService{
+		repo:        repo,
+		userRepo:    userRepo,
+		authService: authService,
+		guard:       guard,
+		mailer:      mailer,
+		loginURL:    loginURL,
+		ttl:         ttl,
+	}
+}
+
+// RequestLink envia un enllaç d'accés al correu del client. Si el correu no
+// existeix, no és d'un client o no s'ha pogut enviar no es retorna cap error,
+// per no revelar quins correus estan registrats. Les peticions massa seguides
+// per al mateix correu o des de la mateixa IP retornen un ThrottledError
+func (s *magicLinkService) RequestLink(ctx context.Context, request MagicLinkRequest, ip string) error {
+	if request.Email == "" {
+		return ErrInvalidRequest
+	}
+	// Es compta abans de buscar el correu perquè la resposta no depengui de si existeix
+	if err := s.guard.Check(ctx, request.Email, ip); err != nil {
+		return err
+	}
+	s.guard.RecordFailure(ctx, request.Email, ip, "magic_link")
+
+	user, err := s.userRepo.FindByEmail(ctx, request.Email)
+	if errors.Is(err, users.ErrUserNotFound) || errors.Is(err, users.ErrInactiveUser) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// El personal ha d'entrar amb la contrasenya: un enllaç convertiria l'accés
+	// al seu correu en accés a l'API
+	if user.Role != roles.RoleCustomer {
+		return nil
+	}
+
+	// Només val l'últim enllaç demanat
+	if err := s.repo.InvalidateByUserID(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Create(ctx, LoginToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.loginURL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Enllaç per entrar a Perretes",
+		Body: fmt.Sprintf("Hola %s,\n\nPer entrar al teu compte obre aquest enllaç:\n%s\n\nL'enllaç només es pot fer servir una vegada i caduca en %s. Si no l'has demanat, ignora aquest correu.\n",
+			user.Username, link, s.ttl),
+	})
+	if err != nil {
+		// No es retorna: l'error només pot passar si el correu existeix i el delataria
+		log.Printf("Error sending magic link email: %v", err)
+	}
+	return nil
+}
+
+// Exchange consumeix l'enllaç i inicia la sessió del client. Obrir l'enllaç
+// demostra que el correu és seu, així que també queda verificat
+func (s *magicLinkService) Exchange(ctx context.Context, request ExchangeRequest, client sessions.ClientInfo) (auth.LoginResult, error) {
+	if request.Token == "" {
+		return auth.LoginResult{}, ErrInvalidRequest
+	}
+
+	userID, err := s.repo.Consume(ctx, utils.HashToken(request.Token))
+	if err != nil {
+		return auth.LoginResult{}, err
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return auth.LoginResult{}, err
+	}
+
+	if user.Role != roles.RoleCustomer {
+		return auth.LoginResult{}, ErrInvalidToken
+	}
+	s.guard.RecordSuccess(ctx, user.Email)
+
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return auth.LoginResult{}, err
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return s.authService.LoginUser(ctx, user, client)
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+	"context"
+	"perretes-api/config"
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer envia correus electrònics. Hi ha una implementació SMTP i una que
+// només els escriu al log per a desenvolupament
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New retorna el mailer SMTP si hi ha un servidor configurat i, si no, el de log
+func New(cfg *config.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		return NewLogMailer()
+	}
+	return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPFrom)
+}
@@ -0,0 +1,11 @@
+package mfa
+
+import "errors"
+
+var (
+	ErrNotEnrolled    = errors.New("two-factor authentication is not set up")
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrInvalidCode    = errors.New("invalid authentication code")
+	ErrNotAllowed     = errors.New("two-factor authentication is only available for staff accounts")
+	ErrInvalidID      = errors.New("invalid user ID")
+)
@@ -0,0 +1,108 @@
+package mfa
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/users"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	service MFAService
+}
+
+func NewMFAHandler(service MFAService) *MFAHandler {
+	return &MFAHandler{service: service}
+}
+
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	status, err := h.service.Status(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *MFAHandler) Setup(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	response, err := h.service.Setup(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *MFAHandler) GetQRCode(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	png, err := h.service.QRCode(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+func (h *MFAHandler) Enable(c *gin.Context) {
+	var request CodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.CurrentUserID(c)
+	codes, err := h.service.Enable(c.Request.Context(), userID, request.Code)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var request CodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.CurrentUserID(c)
+	if err := h.service.Disable(c.Request.Context(), userID, request.Code); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var request CodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.CurrentUserID(c)
+	codes, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), userID, request.Code)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidCode):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrNotAllowed):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrNotEnrolled), errors.Is(err, users.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlreadyEnabled):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
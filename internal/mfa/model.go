@@ -0,0 +1,21 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Enrollment és l'alta d'un usuari a la verificació en dos passos amb TOTP
+type Enrollment struct {
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	EnabledAt    *time.Time `json:"enabled_at" db:"enabled_at"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Enabled indica si l'usuari ja ha confirmat l'alta amb un primer codi
+func (e Enrollment) Enabled() bool {
+	return e.EnabledAt != nil
+}
@@ -0,0 +1,170 @@
+package mfa
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	FindByUserID(ctx context.Context, userID uuid.UUID) (Enrollment, error)
+	SavePending(ctx context.Context, enrollment Enrollment) error
+	Enable(ctx context.Context, userID uuid.UUID, step int64) error
+	MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) FindByUserID(ctx context.Context, userID uuid.UUID) (Enrollment, error) {
+	var e Enrollment
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa WHERE user_id = $1`, userID,
+	).Scan(&e.UserID, &e.Secret, &e.EnabledAt, &e.LastUsedStep, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Enrollment{}, ErrNotEnrolled
+	} else if err != nil {
+		return Enrollment{}, fmt.Errorf("error getting mfa enrollment: %w", err)
+	}
+	return e, nil
+}
+
+// SavePending desa un secret nou pendent de confirmar. Si l'usuari ja té la
+// verificació activada no el substitueix i retorna ErrAlreadyEnabled
+func (r *mfaRepository) SavePending(ctx context.Context, enrollment Enrollment) error {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_mfa (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+		WHERE user_mfa.enabled_at IS NULL`,
+		enrollment.UserID, enrollment.Secret)
+	if err != nil {
+		return fmt.Errorf("error saving mfa enrollment: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *mfaRepository) Enable(ctx context.Context, userID uuid.UUID, step int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_mfa
+		SET enabled_at = now(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL`,
+		userID, step)
+	if err != nil {
+		return fmt.Errorf("error enabling mfa: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAlreadyEnabled
+	}
+	return nil
+}
+
+// MarkStepUsed registra l'últim pas TOTP acceptat. Retorna false si el codi
+// ja s'havia fet servir, perquè un codi interceptat no es pugui reutilitzar
+func (r *mfaRepository) MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_mfa
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2`,
+		userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error updating mfa step: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *mfaRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting mfa enrollment: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes esborra els codis de recuperació anteriors i desa els nous
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash)
+			VALUES ($1, $2, $3)`,
+			uuid.New(), userID, hash)
+		if err != nil {
+			return fmt.Errorf("error inserting recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ConsumeRecoveryCode marca el codi com a utilitzat. Retorna false si no existeix o ja s'havia fet servir
+func (r *mfaRepository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_recovery_codes
+		SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("error consuming recovery code: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT count(*) FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,15 @@
+package mfa
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *MFAHandler) {
+	mfa := router.Group("/me/mfa")
+	{
+		mfa.GET("", handler.GetStatus)
+		mfa.POST("/setup", handler.Setup)
+		mfa.GET("/qr", handler.GetQRCode)
+		mfa.POST("/enable", handler.Enable)
+		mfa.POST("/disable", handler.Disable)
+		mfa.POST("/recovery-codes", handler.RegenerateRecoveryCodes)
+	}
+}
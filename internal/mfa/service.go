@@ -0,0 +1,239 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const recoveryCodeCount = 10
+
+type MFAService interface {
+	Status(ctx context.Context, userID string) (StatusResponse, error)
+	Setup(ctx context.Context, userID string) (SetupResponse, error)
+	QRCode(ctx context.Context, userID string) ([]byte, error)
+	Enable(ctx context.Context, userID string, code string) ([]string, error)
+	Disable(ctx context.Context, userID string, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error)
+	IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+type mfaService struct {
+	repo     MFARepository
+	userRepo users.UserRepository
+	issuer   string
+}
+
+func NewMFAService(repo MFARepository, userRepo users.UserRepository, issuer string) MFAService {
+	return &mfaService{repo: repo, userRepo: userRepo, issuer: issuer}
+}
+
+func (s *mfaService) Status(ctx context.Context, userID string) (StatusResponse, error) {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return StatusResponse{}, ErrInvalidID
+	}
+	enrollment, err := s.repo.FindByUserID(ctx, parsedID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return StatusResponse{}, nil
+	}
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	if !enrollment.Enabled() {
+		return StatusResponse{}, nil
+	}
+	left, err := s.repo.CountRecoveryCodes(ctx, parsedID)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	return StatusResponse{
+		Enabled:           true,
+		EnabledAt:         enrollment.EnabledAt,
+		RecoveryCodesLeft: left,
+	}, nil
+}
+
+// Setup genera un secret nou pendent de confirmar. Només el personal pot
+// activar la verificació en dos passos
+func (s *mfaService) Setup(ctx context.Context, userID string) (SetupResponse, error) {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return SetupResponse{}, ErrInvalidID
+	}
+	user, err := s.userRepo.FindByID(ctx, parsedID)
+	if err != nil {
+		return SetupResponse{}, err
+	}
+	if user.Role == roles.RoleCustomer {
+		return SetupResponse{}, ErrNotAllowed
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return SetupResponse{}, err
+	}
+	err = s.repo.SavePending(ctx, Enrollment{UserID: parsedID, Secret: secret})
+	if err != nil {
+		return SetupResponse{}, err
+	}
+	return SetupResponse{
+		Secret: secret,
+		URI:    otpauthURI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// QRCode retorna el PNG amb l'URI otpauth de l'alta pendent. Un cop activada
+// ja no es torna a mostrar el secret
+func (s *mfaService) QRCode(ctx context.Context, userID string) ([]byte, error) {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	enrollment, err := s.repo.FindByUserID(ctx, parsedID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled() {
+		return nil, ErrAlreadyEnabled
+	}
+	user, err := s.userRepo.FindByID(ctx, parsedID)
+	if err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(otpauthURI(s.issuer, user.Username, enrollment.Secret), qrcode.Medium, 256)
+}
+
+// Enable confirma l'alta amb el primer codi de l'aplicació i retorna els codis de
+// recuperació. És l'únic moment en què es poden veure
+func (s *mfaService) Enable(ctx context.Context, userID string, code string) ([]string, error) {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	enrollment, err := s.repo.FindByUserID(ctx, parsedID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled() {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := validateTOTP(enrollment.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if err := s.repo.Enable(ctx, parsedID, step); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, parsedID)
+}
+
+// Disable desactiva la verificació en dos passos. Cal un codi vàlid perquè un
+// token robat no la pugui treure
+func (s *mfaService) Disable(ctx context.Context, userID string, code string) error {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	if err := s.Verify(ctx, parsedID, code); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, parsedID)
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	if err := s.Verify(ctx, parsedID, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, parsedID)
+}
+
+func (s *mfaService) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	enrollment, err := s.repo.FindByUserID(ctx, userID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrollment.Enabled(), nil
+}
+
+// Verify accepta un codi TOTP o un codi de recuperació. Cada codi només es pot fer servir una vegada
+func (s *mfaService) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	enrollment, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Enabled() {
+		return ErrNotEnrolled
+	}
+
+	code = normalizeCode(code)
+	if step, ok := validateTOTP(enrollment.Secret, code, time.Now()); ok {
+		fresh, err := s.repo.MarkStepUsed(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	consumed, err := s.repo.ConsumeRecoveryCode(ctx, userID, utils.HashToken(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (s *mfaService) newRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeCode(code)))
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode retorna un codi de l'estil "abcde-fghij" fàcil d'apuntar
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(secretEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeCode treu els espais i guions que l'usuari hagi pogut escriure
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,54 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// common_passwords.txt té el format dels rangs de k-anonimat de Have I Been
+// Pwned, així es pot substituir per un bolcat d'aquella llista sense canviar el codi
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var (
+	commonOnce   sync.Once
+	commonRanges map[string]map[string]struct{}
+)
+
+func loadCommonPasswords() {
+	commonRanges = map[string]map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, suffixes, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		set := commonRanges[prefix]
+		if set == nil {
+			set = map[string]struct{}{}
+			commonRanges[prefix] = set
+		}
+		for _, suffix := range strings.Split(suffixes, ",") {
+			set[suffix] = struct{}{}
+		}
+	}
+}
+
+// isCommon busca el SHA-1 de la contrasenya al rang del seu prefix
+func isCommon(password string) bool {
+	commonOnce.Do(loadCommonPasswords)
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := commonRanges[hash[:5]][hash[5:]]
+	return found
+}
@@ -0,0 +1,118 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Codis de les regles que pot incomplir una contrasenya
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeMissingUppercase = "missing_uppercase"
+	CodeMissingLowercase = "missing_lowercase"
+	CodeMissingDigit     = "missing_digit"
+	CodeMissingSymbol    = "missing_symbol"
+	CodeSameAsUsername   = "same_as_username"
+	CodeCommonPassword   = "common_password"
+	CodeReused           = "reused_password"
+)
+
+// bcrypt només té en compte els primers 72 bytes
+const maxLength = 72
+
+// Policy són les regles que ha de complir una contrasenya nova
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// HistorySize és quantes contrasenyes anteriors no es poden tornar a fer servir
+	HistorySize int
+}
+
+// Violation és una regla que la contrasenya no compleix
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError recull totes les regles incomplertes, perquè l'usuari les
+// pugui corregir d'una vegada
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	return "password does not meet the password policy"
+}
+
+// Validate comprova la contrasenya contra la política. previousHashes són els
+// hashes bcrypt de les últimes contrasenyes de l'usuari
+func (p Policy) Validate(password, username string, previousHashes []string) error {
+	var violations []Violation
+	add := func(code, message string) {
+		violations = append(violations, Violation{Code: code, Message: message})
+	}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		add(CodeTooShort, "password is too short")
+	}
+	if len(password) > maxLength {
+		add(CodeTooLong, "password is too long")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(CodeMissingUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(CodeMissingLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(CodeMissingDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(CodeMissingSymbol, "password must contain a symbol")
+	}
+
+	if username != "" && strings.EqualFold(password, username) {
+		add(CodeSameAsUsername, "password must not be the username")
+	}
+	if isCommon(password) {
+		add(CodeCommonPassword, "password is too common")
+	}
+	if p.reused(password, previousHashes) {
+		add(CodeReused, "password was used recently")
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func (p Policy) reused(password string, previousHashes []string) bool {
+	for _, hash := range previousHashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
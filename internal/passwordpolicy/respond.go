@@ -0,0 +1,22 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RespondInvalid respon 400 amb les regles incomplertes si l'error és de la
+// política de contrasenyes. Retorna false si és un altre error
+func RespondInvalid(c *gin.Context, err error) bool {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      err.Error(),
+		"violations": validationErr.Violations,
+	})
+	return true
+}
@@ -0,0 +1,8 @@
+package passwordreset
+
+import "errors"
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired reset token")
+	ErrInvalidRequest = errors.New("invalid request")
+)
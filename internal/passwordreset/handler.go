@@ -0,0 +1,66 @@
+package passwordreset
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetHandler struct {
+	service PasswordResetService
+}
+
+func NewPasswordResetHandler(service PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{service: service}
+}
+
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var request ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.RequestReset(c.Request.Context(), request)
+	if errors.Is(err, ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Qualsevol altre error només es pot produir si el correu existeix
+	if err != nil {
+		log.Printf("Error requesting password reset: %v", err)
+	}
+
+	// Sempre la mateixa resposta, existeixi o no el correu
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email exists, a reset link has been sent"})
+}
+
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var request ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.Reset(c.Request.Context(), request)
+	if err != nil {
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrInvalidRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, users.ErrInactiveUser):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
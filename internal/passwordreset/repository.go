@@ -0,0 +1,68 @@
+package passwordreset
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type ResetTokenRepository interface {
+	Create(ctx context.Context, token ResetToken) (ResetToken, error)
+	InvalidateByUserID(ctx context.Context, userID uuid.UUID) error
+	Consume(ctx context.Context, tokenHash string) (uuid.UUID, error)
+}
+
+type resetTokenRepository struct {
+	db *sql.DB
+}
+
+func NewResetTokenRepository(db *sql.DB) ResetTokenRepository {
+	return &resetTokenRepository{db: db}
+}
+
+func (r *resetTokenRepository) Create(ctx context.Context, token ResetToken) (ResetToken, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		token.ID, token.UserID, token.TokenHash, token.ExpiresAt,
+	)
+	if err != nil {
+		return ResetToken{}, fmt.Errorf("error inserting reset token: %w", err)
+	}
+	return token, nil
+}
+
+// InvalidateByUserID marca com a utilitzats els tokens pendents de l'usuari
+func (r *resetTokenRepository) InvalidateByUserID(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = now()
+		WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error invalidating reset tokens: %w", err)
+	}
+	return nil
+}
+
+// Consume marca el token com a utilitzat i retorna l'usuari al qual pertany.
+// Es fa en una sola sentència perquè el mateix token no es pugui fer servir dues vegades
+func (r *resetTokenRepository) Consume(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`,
+		tokenHash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrInvalidToken
+	} else if err != nil {
+		return uuid.Nil, fmt.Errorf("error consuming reset token: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,11 @@
+package passwordreset
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *PasswordResetHandler) {
+	password := router.Group("/password")
+	{
+		password.POST("/forgot", handler.ForgotPassword)
+		password.POST("/reset", handler.ResetPassword)
+	}
+}
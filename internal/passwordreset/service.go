@@ -0,0 +1,104 @@
+package passwordreset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"perretes-api/internal/mailer"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetService interface {
+	RequestReset(ctx context.Context, request ForgotPasswordRequest) error
+	Reset(ctx context.Context, request ResetPasswordRequest) error
+}
+
+type passwordResetService struct {
+	repo        ResetTokenRepository
+	userRepo    users.UserRepository
+	userService users.UserService
+	mailer      mailer.Mailer
+	resetURL    string
+	ttl         time.Duration
+}
+
+func NewPasswordResetService(repo ResetTokenRepository, userRepo users.UserRepository, userService users.UserService, mailer mailer.Mailer, resetURL string, ttl time.Duration) PasswordResetService {
+	return &passwordResetService{
+		repo:        repo,
+		userRepo:    userRepo,
+		userService: userService,
+		mailer:      mailer,
+		resetURL:    resetURL,
+		ttl:         ttl,
+	}
+}
+
+// RequestReset genera un token i l'envia per correu. Si el correu no existeix o
+// no s'ha pogut enviar no es retorna cap error, per no revelar quins correus
+// estan registrats
+func (s *passwordResetService) RequestReset(ctx context.Context, request ForgotPasswordRequest) error {
+	if request.Email == "" {
+		return ErrInvalidRequest
+	}
+
+	// El correu de l'usuari és únic, així també es troben els comptes de personal
+	user, err := s.userRepo.FindByEmail(ctx, request.Email)
+	if errors.Is(err, users.ErrUserNotFound) || errors.Is(err, users.ErrInactiveUser) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.InvalidateByUserID(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Create(ctx, ResetToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Restabliment de contrasenya",
+		Body: fmt.Sprintf("Hola %s,\n\nPer triar una nova contrasenya obre aquest enllaç:\n%s\n\nL'enllaç caduca en %s. Si no has demanat el canvi, ignora aquest correu.\n",
+			user.Username, link, s.ttl),
+	})
+	if err != nil {
+		// No es retorna: l'error només pot passar si el correu existeix i el delataria
+		log.Printf("Error sending password reset email: %v", err)
+	}
+	return nil
+}
+
+// Reset consumeix el token i canvia la contrasenya de l'usuari
+func (s *passwordResetService) Reset(ctx context.Context, request ResetPasswordRequest) error {
+	if request.Token == "" || request.Password == "" {
+		return ErrInvalidRequest
+	}
+
+	userID, err := s.repo.Consume(ctx, utils.HashToken(request.Token))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.userService.ResetPassword(ctx, userID.String(), request.Password)
+	return err
+}
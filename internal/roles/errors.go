@@ -0,0 +1,8 @@
+package roles
+
+import "errors"
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrInvalidRole  = errors.New("invalid role")
+)
@@ -0,0 +1,49 @@
+package roles
+
+import "github.com/google/uuid"
+
+const (
+	RoleAdmin    = "admin"
+	RoleTrainer  = "trainer"
+	RoleCustomer = "customer"
+)
+
+const (
+	PermCoursesRead      = "courses:read"
+	PermCoursesWrite     = "courses:write"
+	PermEnrollmentsRead  = "enrollments:read"
+	PermEnrollmentsWrite = "enrollments:write"
+	PermCustomersRead    = "customers:read"
+	PermCustomersWrite   = "customers:write"
+	PermUsersWrite       = "users:write"
+	PermUsersImpersonate = "users:impersonate"
+)
+
+// IsOwnerPermission indica si el permís protegeix recursos que qualsevol usuari
+// pot fer servir quan en és el propietari, com les seves inscripcions o fitxa
+// de client. Una clau d'API pot tenir aquests scopes encara que el rol no els
+// tingui, perquè amb ells només arriba als recursos del seu propietari. users:write
+// no hi és: canviar la contrasenya o el compte no es pot fer mai amb una clau
+func IsOwnerPermission(permission string) bool {
+	switch permission {
+	case PermEnrollmentsRead, PermEnrollmentsWrite, PermCustomersRead, PermCustomersWrite:
+		return true
+	}
+	return false
+}
+
+type Role struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions"`
+}
+
+// IsValid indica si el nom correspon a un dels rols coneguts
+func IsValid(name string) bool {
+	switch name {
+	case RoleAdmin, RoleTrainer, RoleCustomer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package roles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type RoleRepository interface {
+	FindByName(ctx context.Context, name string) (Role, error)
+}
+
+type roleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name string) (Role, error) {
+	var role Role
+	var description sql.NullString
+	err := r.db.QueryRowContext(ctx, `SELECT id, name, description FROM roles WHERE name = $1`, name).
+		Scan(&role.ID, &role.Name, &description)
+	if err == sql.ErrNoRows {
+		return Role{}, ErrRoleNotFound
+	} else if err != nil {
+		return Role{}, fmt.Errorf("error getting role: %w", err)
+	}
+	role.Description = description.String
+
+	permissions, err := r.findPermissions(ctx, role.Name)
+	if err != nil {
+		return Role{}, err
+	}
+	role.Permissions = permissions
+	return role, nil
+}
+
+func (r *roleRepository) findPermissions(ctx context.Context, roleName string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.name
+		FROM role_permissions rp
+		JOIN roles r ON r.id = rp.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.name = $1
+		ORDER BY p.name`, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("error scanning permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
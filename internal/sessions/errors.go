@@ -0,0 +1,10 @@
+package sessions
+
+import "errors"
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidID       = errors.New("invalid session ID")
+	ErrInvalidRefresh  = errors.New("invalid or expired refresh token")
+	ErrRefreshReused   = errors.New("refresh token reused, session revoked")
+)
@@ -0,0 +1,65 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	service SessionService
+}
+
+func NewSessionHandler(service SessionService) *SessionHandler {
+	return &SessionHandler{service: service}
+}
+
+// Logout revoca la sessió del token amb què es fa la petició
+func (h *SessionHandler) Logout(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	err := h.service.Revoke(c.Request.Context(), userID, currentSessionID(c))
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidID) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *SessionHandler) GetMySessions(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	sessions, err := h.service.FindByUserID(c.Request.Context(), userID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *SessionHandler) DeleteMySession(c *gin.Context) {
+	userID, _ := middleware.CurrentUserID(c)
+	err := h.service.Revoke(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidID):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrSessionNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func currentSessionID(c *gin.Context) string {
+	jti, _ := jwt.ExtractClaims(c)["jti"].(string)
+	return jti
+}
@@ -0,0 +1,155 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session Session) (Session, error)
+	FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	FindByID(ctx context.Context, id uuid.UUID) (Session, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID) error
+	RevokeByID(ctx context.Context, id uuid.UUID) error
+	CreateRefreshToken(ctx context.Context, token RefreshToken) (RefreshToken, error)
+	FindRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session Session) (Session, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO sessions (id, user_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, last_seen_at`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return Session{}, fmt.Errorf("error inserting session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt); err != nil {
+			return nil, fmt.Errorf("error scanning session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke revoca una sessió de l'usuari. Retorna ErrSessionNotFound si no és seva o ja estava revocada
+func (r *sessionRepository) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) FindByID(ctx context.Context, id uuid.UUID) (Session, error) {
+	var s Session
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions WHERE id = $1`, id,
+	).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	if err == sql.ErrNoRows {
+		return Session{}, ErrSessionNotFound
+	} else if err != nil {
+		return Session{}, fmt.Errorf("error getting session: %w", err)
+	}
+	return s, nil
+}
+
+// RevokeByID revoca la sessió sense comprovar l'usuari. Amb la sessió queden
+// invalidats tots els refresh tokens de la família
+func (r *sessionRepository) RevokeByID(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL`,
+		id)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) CreateRefreshToken(ctx context.Context, token RefreshToken) (RefreshToken, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, session_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.ID, token.SessionID, token.UserID, token.TokenHash, token.ExpiresAt,
+	)
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("error inserting refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *sessionRepository) FindRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	var t RefreshToken
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, session_id, user_id, token_hash, expires_at, used_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1`, tokenHash,
+	).Scan(&t.ID, &t.SessionID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return RefreshToken{}, ErrInvalidRefresh
+	} else if err != nil {
+		return RefreshToken{}, fmt.Errorf("error getting refresh token: %w", err)
+	}
+	return t, nil
+}
+
+// MarkRefreshTokenUsed marca el token com a utilitzat. Retorna false si un altre
+// procés ja l'havia fet servir
+func (r *sessionRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL`,
+		id)
+	if err != nil {
+		return false, fmt.Errorf("error marking refresh token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
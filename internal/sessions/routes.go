@@ -0,0 +1,18 @@
+package sessions
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *SessionHandler) {
+	sessions := router.Group("/me/sessions")
+	{
+		sessions.GET("", handler.GetMySessions)
+		sessions.DELETE("/:id", handler.DeleteMySession)
+	}
+}
+
+// RegisterLogoutRoute registra el logout. Cal passar-li el middleware JWT perquè
+// la resta de rutes de /auth són públiques
+func RegisterLogoutRoute(router *gin.RouterGroup, handler *SessionHandler, authMiddlewares ...gin.HandlerFunc) {
+	handlers := append(authMiddlewares, handler.Logout)
+	router.POST("/logout", handlers...)
+}
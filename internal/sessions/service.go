@@ -0,0 +1,136 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"perretes-api/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionService interface {
+	Start(ctx context.Context, userID uuid.UUID, client ClientInfo, expiresAt time.Time) (Session, error)
+	FindByUserID(ctx context.Context, userID, currentID string) ([]Session, error)
+	Revoke(ctx context.Context, userID, id string) error
+	IssueRefreshToken(ctx context.Context, session Session) (string, time.Time, error)
+	RotateRefreshToken(ctx context.Context, token string) (Session, string, time.Time, error)
+}
+
+type sessionService struct {
+	repo SessionRepository
+}
+
+func NewSessionService(repo SessionRepository) SessionService {
+	return &sessionService{repo: repo}
+}
+
+// Start crea la sessió que identificarà el token amb el claim jti
+func (s *sessionService) Start(ctx context.Context, userID uuid.UUID, client ClientInfo, expiresAt time.Time) (Session, error) {
+	return s.repo.Create(ctx, Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		UserAgent: truncate(client.UserAgent, 500),
+		IPAddress: truncate(client.IPAddress, 64),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *sessionService) FindByUserID(ctx context.Context, userID, currentID string) ([]Session, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	sessions, err := s.repo.FindActiveByUserID(ctx, parsedUserID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == currentID
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, id string) error {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	return s.repo.Revoke(ctx, parsedID, parsedUserID)
+}
+
+// IssueRefreshToken genera un refresh token nou per a la sessió. Caduca quan ho fa la sessió
+func (s *sessionService) IssueRefreshToken(ctx context.Context, session Session) (string, time.Time, error) {
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	_, err = s.repo.CreateRefreshToken(ctx, RefreshToken{
+		ID:        uuid.New(),
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+// RotateRefreshToken canvia un refresh token per un de nou de la mateixa família.
+// Si el token ja s'havia fet servir, algú l'ha copiat: es revoca tota la sessió
+func (s *sessionService) RotateRefreshToken(ctx context.Context, token string) (Session, string, time.Time, error) {
+	refreshToken, err := s.repo.FindRefreshToken(ctx, utils.HashToken(token))
+	if err != nil {
+		return Session{}, "", time.Time{}, err
+	}
+	if refreshToken.UsedAt != nil {
+		return Session{}, "", time.Time{}, s.revokeFamily(ctx, refreshToken)
+	}
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return Session{}, "", time.Time{}, ErrInvalidRefresh
+	}
+
+	session, err := s.repo.FindByID(ctx, refreshToken.SessionID)
+	if err != nil {
+		return Session{}, "", time.Time{}, err
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return Session{}, "", time.Time{}, ErrInvalidRefresh
+	}
+
+	marked, err := s.repo.MarkRefreshTokenUsed(ctx, refreshToken.ID)
+	if err != nil {
+		return Session{}, "", time.Time{}, err
+	}
+	if !marked {
+		// Dues peticions amb el mateix token alhora
+		return Session{}, "", time.Time{}, s.revokeFamily(ctx, refreshToken)
+	}
+
+	newToken, expire, err := s.IssueRefreshToken(ctx, session)
+	if err != nil {
+		return Session{}, "", time.Time{}, err
+	}
+	return session, newToken, expire, nil
+}
+
+func (s *sessionService) revokeFamily(ctx context.Context, refreshToken RefreshToken) error {
+	log.Printf("Refresh token reused for session %s, revoking it", refreshToken.SessionID)
+	if err := s.repo.RevokeByID(ctx, refreshToken.SessionID); err != nil {
+		return err
+	}
+	return ErrRefreshReused
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
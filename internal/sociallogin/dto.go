@@ -0,0 +1,11 @@
+package sociallogin
+
+type AuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// CallbackRequest són els paràmetres amb què el proveïdor torna al frontend
+type CallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
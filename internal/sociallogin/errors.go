@@ -0,0 +1,15 @@
+package sociallogin
+
+import "errors"
+
+var (
+	ErrProviderNotFound = errors.New("unknown identity provider")
+	ErrInvalidState     = errors.New("invalid or expired login state")
+	ErrExchangeFailed   = errors.New("could not exchange the authorization code")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+	ErrEmailNotVerified = errors.New("the identity provider has not verified the email")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrInvalidID        = errors.New("invalid user ID")
+	ErrLinkRequired     = errors.New("an account with this email already exists: log in and link the provider from your account")
+	ErrIdentityLinked   = errors.New("this identity is already linked to another account")
+)
@@ -0,0 +1,83 @@
+package sociallogin
+
+import (
+	"errors"
+	"net/http"
+	"perretes-api/internal/auth"
+	"perretes-api/internal/users"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SocialLoginHandler struct {
+	service SocialLoginService
+}
+
+func NewSocialLoginHandler(service SocialLoginService) *SocialLoginHandler {
+	return &SocialLoginHandler{service: service}
+}
+
+// Authorize retorna l'URL del proveïdor on el frontend ha de redirigir l'usuari
+func (h *SocialLoginHandler) Authorize(c *gin.Context) {
+	url, err := h.service.AuthorizationURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrProviderNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, AuthorizationResponse{AuthorizationURL: url})
+}
+
+// Link retorna l'URL del proveïdor per enllaçar-lo amb el compte de l'usuari autenticat
+func (h *SocialLoginHandler) Link(c *gin.Context) {
+	id, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidID.Error()})
+		return
+	}
+	url, err := h.service.LinkURL(c.Request.Context(), c.Param("provider"), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrProviderNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidID):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, AuthorizationResponse{AuthorizationURL: url})
+}
+
+// Callback rep el codi i el state que el proveïdor ha tornat al frontend i
+// respon com el login amb contrasenya
+func (h *SocialLoginHandler) Callback(c *gin.Context) {
+	var request CallbackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Callback(c.Request.Context(), c.Param("provider"), request, auth.ClientInfo(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrProviderNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidState), errors.Is(err, ErrExchangeFailed), errors.Is(err, ErrInvalidIDToken):
+			status = http.StatusUnauthorized
+		case errors.Is(err, ErrEmailNotVerified), errors.Is(err, users.ErrInactiveUser):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrLinkRequired), errors.Is(err, ErrIdentityLinked):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	auth.RespondLogin(c, result)
+}
@@ -0,0 +1,51 @@
+package sociallogin
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity enllaça el compte d'un proveïdor extern amb un usuari
+type Identity struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Provider    string    `json:"provider" db:"provider"`
+	Subject     string    `json:"subject" db:"subject"`
+	Email       string    `json:"email" db:"email"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+}
+
+// LoginState és un login iniciat amb un proveïdor. Es busca pel hash del state
+// que torna el proveïdor i només es pot fer servir una vegada. UserID és
+// l'usuari que enllaça la identitat des del seu compte, si n'hi ha
+type LoginState struct {
+	ID           uuid.UUID  `db:"id"`
+	StateHash    string     `db:"state_hash"`
+	Provider     string     `db:"provider"`
+	CodeVerifier string     `db:"code_verifier"`
+	Nonce        string     `db:"nonce"`
+	UserID       *uuid.UUID `db:"user_id"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	UsedAt       *time.Time `db:"used_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// ProviderConfig és la configuració d'un proveïdor OpenID Connect
+type ProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// idTokenClaims són els claims de l'ID token que fem servir
+type idTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
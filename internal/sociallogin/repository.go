@@ -0,0 +1,98 @@
+package sociallogin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"perretes-api/internal/txn"
+
+	"github.com/google/uuid"
+)
+
+type IdentityRepository interface {
+	CreateState(ctx context.Context, state LoginState) error
+	ConsumeState(ctx context.Context, stateHash, provider string) (LoginState, error)
+	FindIdentity(ctx context.Context, provider, subject string) (Identity, error)
+	CreateIdentity(ctx context.Context, identity Identity) (Identity, error)
+	TouchIdentity(ctx context.Context, id uuid.UUID) error
+}
+
+type identityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+// conn retorna la transacció de la unitat de treball del context, si n'hi ha
+func (r *identityRepository) conn(ctx context.Context) txn.DBTX {
+	return txn.Conn(ctx, r.db)
+}
+
+func (r *identityRepository) CreateState(ctx context.Context, state LoginState) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		INSERT INTO oidc_login_states (id, state_hash, provider, code_verifier, nonce, user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		state.ID, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.UserID, state.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting login state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeState marca el state com a utilitzat i el retorna, tot en una sola
+// operació perquè dues peticions amb el mateix state no el puguin fer servir
+func (r *identityRepository) ConsumeState(ctx context.Context, stateHash, provider string) (LoginState, error) {
+	var s LoginState
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		UPDATE oidc_login_states
+		SET used_at = now()
+		WHERE state_hash = $1 AND provider = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING id, state_hash, provider, code_verifier, nonce, user_id, expires_at, used_at, created_at`,
+		stateHash, provider,
+	).Scan(&s.ID, &s.StateHash, &s.Provider, &s.CodeVerifier, &s.Nonce, &s.UserID, &s.ExpiresAt, &s.UsedAt, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return LoginState{}, ErrInvalidState
+	} else if err != nil {
+		return LoginState{}, fmt.Errorf("error consuming login state: %w", err)
+	}
+	return s, nil
+}
+
+func (r *identityRepository) FindIdentity(ctx context.Context, provider, subject string) (Identity, error) {
+	var i Identity
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at, last_login_at
+		FROM identities WHERE provider = $1 AND subject = $2`,
+		provider, subject,
+	).Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err == sql.ErrNoRows {
+		return Identity{}, ErrIdentityNotFound
+	} else if err != nil {
+		return Identity{}, fmt.Errorf("error getting identity: %w", err)
+	}
+	return i, nil
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identity Identity) (Identity, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, last_login_at`,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email,
+	).Scan(&identity.CreatedAt, &identity.LastLoginAt)
+	if err != nil {
+		return Identity{}, fmt.Errorf("error inserting identity: %w", err)
+	}
+	return identity, nil
+}
+
+func (r *identityRepository) TouchIdentity(ctx context.Context, id uuid.UUID) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `UPDATE identities SET last_login_at = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error updating identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package sociallogin
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *SocialLoginHandler) {
+	oidc := router.Group("/oidc/:provider")
+	{
+		oidc.GET("", handler.Authorize)
+		oidc.POST("/callback", handler.Callback)
+	}
+}
+
+// RegisterAccountRoutes registra l'enllaç d'un proveïdor amb el compte de
+// l'usuari autenticat
+func RegisterAccountRoutes(router *gin.RouterGroup, handler *SocialLoginHandler) {
+	router.GET("/me/identities/:provider", handler.Link)
+}
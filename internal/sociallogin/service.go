@@ -0,0 +1,315 @@
+package sociallogin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"perretes-api/internal/auth"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
+	"perretes-api/internal/txn"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// Temps que té l'usuari per tornar del proveïdor
+const stateTTL = 10 * time.Minute
+
+type SocialLoginService interface {
+	AuthorizationURL(ctx context.Context, provider string) (string, error)
+	LinkURL(ctx context.Context, provider, userID string) (string, error)
+	Callback(ctx context.Context, provider string, request CallbackRequest, client sessions.ClientInfo) (auth.LoginResult, error)
+}
+
+// provider és un proveïdor OpenID Connect. El document de descobriment es
+// carrega el primer cop que es fa servir, perquè si el proveïdor no respon
+// l'API pugui arrencar igualment
+type provider struct {
+	config   ProviderConfig
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func (p *provider) load(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	discovered, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error discovering provider %s: %w", p.config.Name, err)
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+	p.verifier = discovered.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+type socialLoginService struct {
+	repo            IdentityRepository
+	userRepo        users.UserRepository
+	customerService customers.CustomerService
+	authService     auth.AuthService
+	uow             txn.UnitOfWork
+	providers       map[string]*provider
+}
+
+func NewSocialLoginService(repo IdentityRepository, userRepo users.UserRepository, customerService customers.CustomerService, authService auth.AuthService, uow txn.UnitOfWork, providers ...ProviderConfig) SocialLoginService {
+	configured := map[string]*provider{}
+	for _, p := range providers {
+		if p.IssuerURL != "" && p.ClientID != "" {
+			configured[p.Name] = &provider{config: p}
+		}
+	}
+	return &socialLoginService{
+		repo:            repo,
+		userRepo:        userRepo,
+		customerService: customerService,
+		authService:     authService,
+		uow:             uow,
+		providers:       configured,
+	}
+}
+
+// AuthorizationURL inicia el flux authorization code amb PKCE i retorna l'URL
+// del proveïdor on s'ha de redirigir el navegador
+func (s *socialLoginService) AuthorizationURL(ctx context.Context, providerName string) (string, error) {
+	return s.authorize(ctx, providerName, nil)
+}
+
+// LinkURL inicia el mateix flux des del compte de l'usuari. Quan torni, la
+// identitat s'enllaçarà amb aquest usuari sigui quin sigui el correu
+func (s *socialLoginService) LinkURL(ctx context.Context, providerName, userID string) (string, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", ErrInvalidID
+	}
+	return s.authorize(ctx, providerName, &parsedUserID)
+}
+
+func (s *socialLoginService) authorize(ctx context.Context, providerName string, userID *uuid.UUID) (string, error) {
+	p, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrProviderNotFound
+	}
+	oauthConfig, _, err := p.load(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.repo.CreateState(ctx, LoginState{
+		ID:           uuid.New(),
+		StateHash:    utils.HashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(stateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Callback canvia el codi per l'ID token, el verifica i inicia la sessió de
+// l'usuari enllaçat. La primera vegada crea l'enllaç i, si cal, el client. Si
+// el login s'ha iniciat des del compte, enllaça la identitat amb aquell usuari
+func (s *socialLoginService) Callback(ctx context.Context, providerName string, request CallbackRequest, client sessions.ClientInfo) (auth.LoginResult, error) {
+	p, ok := s.providers[providerName]
+	if !ok {
+		return auth.LoginResult{}, ErrProviderNotFound
+	}
+	oauthConfig, verifier, err := p.load(ctx)
+	if err != nil {
+		return auth.LoginResult{}, err
+	}
+
+	state, err := s.repo.ConsumeState(ctx, utils.HashToken(request.State), providerName)
+	if err != nil {
+		return auth.LoginResult{}, err
+	}
+
+	token, err := oauthConfig.Exchange(ctx, request.Code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		log.Printf("Error exchanging code with %s: %v", providerName, err)
+		return auth.LoginResult{}, ErrExchangeFailed
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return auth.LoginResult{}, ErrInvalidIDToken
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Printf("Error verifying id token from %s: %v", providerName, err)
+		return auth.LoginResult{}, ErrInvalidIDToken
+	}
+	if idToken.Nonce != state.Nonce {
+		return auth.LoginResult{}, ErrInvalidIDToken
+	}
+	var claims idTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return auth.LoginResult{}, ErrInvalidIDToken
+	}
+
+	var user users.User
+	if state.UserID != nil {
+		user, err = s.linkUser(ctx, *state.UserID, providerName, idToken.Subject, claims)
+	} else {
+		user, err = s.resolveUser(ctx, providerName, idToken.Subject, claims)
+	}
+	if err != nil {
+		return auth.LoginResult{}, err
+	}
+	return s.authService.LoginUser(ctx, user, client)
+}
+
+// linkUser enllaça la identitat amb l'usuari que ha iniciat el login des del
+// seu compte. Si ja està enllaçada amb un altre usuari no es mou
+func (s *socialLoginService) linkUser(ctx context.Context, userID uuid.UUID, providerName, subject string, claims idTokenClaims) (users.User, error) {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		identity, err := s.repo.FindIdentity(ctx, providerName, subject)
+		if err == nil {
+			if identity.UserID != userID {
+				return ErrIdentityLinked
+			}
+			return s.repo.TouchIdentity(ctx, identity.ID)
+		}
+		if !errors.Is(err, ErrIdentityNotFound) {
+			return err
+		}
+		_, err = s.repo.CreateIdentity(ctx, Identity{
+			ID:       uuid.New(),
+			UserID:   userID,
+			Provider: providerName,
+			Subject:  subject,
+			Email:    claims.Email,
+		})
+		return err
+	})
+	if err != nil {
+		return users.User{}, err
+	}
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+// resolveUser troba l'usuari enllaçat a la identitat. Si no n'hi ha cap,
+// l'enllaça amb el client que té el mateix correu o en crea un de nou. Només
+// s'enllaça per correu si el proveïdor l'ha verificat
+func (s *socialLoginService) resolveUser(ctx context.Context, providerName, subject string, claims idTokenClaims) (users.User, error) {
+	identity, err := s.repo.FindIdentity(ctx, providerName, subject)
+	if err == nil {
+		if err := s.repo.TouchIdentity(ctx, identity.ID); err != nil {
+			return users.User{}, err
+		}
+		return s.userRepo.FindByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return users.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return users.User{}, ErrEmailNotVerified
+	}
+
+	// La fitxa de client, l'usuari i l'enllaç es creen junts: si l'enllaç falla,
+	// no queda un compte nou que bloquegi el correu al següent intent
+	var userID uuid.UUID
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// El correu de l'usuari és únic, però només s'enllaça sol amb un client
+		// que l'ha verificat: si no, qui hagués registrat el correu d'un altre
+		// rebria el seu login. La resta ho han d'enllaçar des del compte
+		user, err := s.userRepo.FindByEmail(ctx, claims.Email)
+		switch {
+		case err == nil:
+			if user.Role != roles.RoleCustomer || user.EmailVerifiedAt == nil {
+				return ErrLinkRequired
+			}
+			userID = user.ID
+		case errors.Is(err, users.ErrUserNotFound):
+			name, surname := customerName(claims)
+			created, err := s.customerService.CreateFromIdentity(ctx, customers.IdentityCustomerRequest{
+				Name:     name,
+				Surname:  surname,
+				Email:    claims.Email,
+				Username: usernameFromEmail(claims.Email),
+			})
+			if err != nil {
+				return err
+			}
+			userID = created.User.ID
+		default:
+			return err
+		}
+
+		_, err = s.repo.CreateIdentity(ctx, Identity{
+			ID:       uuid.New(),
+			UserID:   userID,
+			Provider: providerName,
+			Subject:  subject,
+			Email:    claims.Email,
+		})
+		return err
+	})
+	if err != nil {
+		return users.User{}, err
+	}
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+func customerName(claims idTokenClaims) (string, string) {
+	if claims.GivenName != "" {
+		return claims.GivenName, claims.FamilyName
+	}
+	if claims.Name != "" {
+		name, surname, _ := strings.Cut(claims.Name, " ")
+		return name, surname
+	}
+	return usernameFromEmail(claims.Email), ""
+}
+
+// usernameFromEmail fa servir la part local del correu com a nom d'usuari
+func usernameFromEmail(email string) string {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	username := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, local)
+	if len(username) > 30 {
+		username = username[:30]
+	}
+	if username == "" {
+		username = "user"
+	}
+	return username
+}
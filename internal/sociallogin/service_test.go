@@ -0,0 +1,398 @@
+package sociallogin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"perretes-api/internal/auth"
+	"perretes-api/internal/customers"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
+	"perretes-api/internal/users"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const testClientID = "perretes"
+
+// mockIssuer és un proveïdor OpenID Connect local: serveix el descobriment, les
+// claus i el token endpoint, que comprova el PKCE i signa l'ID token
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(m.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   m.server.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": m.nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authorize fa el paper del navegador: agafa el state, el nonce i el challenge
+// de l'URL d'autorització i prepara l'ID token que tornarà el proveïdor
+func (m *mockIssuer) authorize(t *testing.T, authorizationURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization URL without PKCE: %s", authorizationURL)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenge = query.Get("code_challenge")
+	m.nonce = query.Get("nonce")
+	m.claims = claims
+	return query.Get("state")
+}
+
+type fakeIdentityRepository struct {
+	states     map[string]LoginState
+	identities []Identity
+}
+
+func (r *fakeIdentityRepository) CreateState(ctx context.Context, state LoginState) error {
+	r.states[state.StateHash] = state
+	return nil
+}
+
+func (r *fakeIdentityRepository) ConsumeState(ctx context.Context, stateHash, provider string) (LoginState, error) {
+	state, ok := r.states[stateHash]
+	if !ok || state.Provider != provider {
+		return LoginState{}, ErrInvalidState
+	}
+	delete(r.states, stateHash)
+	return state, nil
+}
+
+func (r *fakeIdentityRepository) FindIdentity(ctx context.Context, provider, subject string) (Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return Identity{}, ErrIdentityNotFound
+}
+
+func (r *fakeIdentityRepository) CreateIdentity(ctx context.Context, identity Identity) (Identity, error) {
+	r.identities = append(r.identities, identity)
+	return identity, nil
+}
+
+func (r *fakeIdentityRepository) TouchIdentity(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+type fakeUserRepository struct {
+	users.UserRepository
+	users map[uuid.UUID]users.User
+}
+
+func (r *fakeUserRepository) FindByID(ctx context.Context, id uuid.UUID) (users.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return users.User{}, users.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepository) FindByEmail(ctx context.Context, email string) (users.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return users.User{}, users.ErrUserNotFound
+}
+
+type fakeCustomerService struct {
+	customers.CustomerService
+	userRepo *fakeUserRepository
+	created  []customers.IdentityCustomerRequest
+}
+
+func (s *fakeCustomerService) CreateFromIdentity(ctx context.Context, request customers.IdentityCustomerRequest) (customers.Customer, error) {
+	s.created = append(s.created, request)
+	now := time.Now()
+	user := users.User{ID: uuid.New(), Username: request.Username, Email: request.Email, IsActive: true, Role: roles.RoleCustomer, EmailVerifiedAt: &now}
+	s.userRepo.users[user.ID] = user
+	return customers.Customer{ID: uuid.New(), Name: request.Name, Email: request.Email, User: users.NewUserResponse(user)}, nil
+}
+
+type fakeAuthService struct {
+	auth.AuthService
+}
+
+func (s *fakeAuthService) LoginUser(ctx context.Context, user users.User, client sessions.ClientInfo) (auth.LoginResult, error) {
+	return auth.LoginResult{User: users.NewUserResponse(user)}, nil
+}
+
+// passthroughUnitOfWork executa fn sense transacció: els repositoris són en memòria
+type passthroughUnitOfWork struct{}
+
+func (passthroughUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type testSetup struct {
+	issuer    *mockIssuer
+	service   SocialLoginService
+	repo      *fakeIdentityRepository
+	userRepo  *fakeUserRepository
+	customers *fakeCustomerService
+}
+
+func newTestSetup(t *testing.T, existing ...users.User) *testSetup {
+	t.Helper()
+	issuer := newMockIssuer(t)
+	repo := &fakeIdentityRepository{states: map[string]LoginState{}}
+	userRepo := &fakeUserRepository{users: map[uuid.UUID]users.User{}}
+	for _, user := range existing {
+		userRepo.users[user.ID] = user
+	}
+	customerService := &fakeCustomerService{userRepo: userRepo}
+	service := NewSocialLoginService(repo, userRepo, customerService, &fakeAuthService{}, passthroughUnitOfWork{}, ProviderConfig{
+		Name:         "mock",
+		IssuerURL:    issuer.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/oidc/callback",
+	})
+	return &testSetup{issuer: issuer, service: service, repo: repo, userRepo: userRepo, customers: customerService}
+}
+
+// login fa el flux sencer: URL d'autorització, tornada del proveïdor i callback
+func (s *testSetup) login(t *testing.T, claims jwt.MapClaims) (auth.LoginResult, error) {
+	t.Helper()
+	authorizationURL, err := s.service.AuthorizationURL(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	state := s.issuer.authorize(t, authorizationURL, claims)
+	return s.service.Callback(context.Background(), "mock", CallbackRequest{Code: "code", State: state}, sessions.ClientInfo{})
+}
+
+func verifiedClaims(subject, email string) jwt.MapClaims {
+	return jwt.MapClaims{"sub": subject, "email": email, "email_verified": true, "name": "Laia Puig"}
+}
+
+func TestCallbackCreatesCustomerOnFirstLogin(t *testing.T) {
+	setup := newTestSetup(t)
+
+	result, err := setup.login(t, verifiedClaims("sub-1", "laia@example.com"))
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if len(setup.customers.created) != 1 {
+		t.Fatalf("customers created = %d, want 1", len(setup.customers.created))
+	}
+	if got := setup.customers.created[0]; got.Name != "Laia" || got.Surname != "Puig" || got.Username != "laia" {
+		t.Fatalf("customer request = %+v", got)
+	}
+	if len(setup.repo.identities) != 1 || setup.repo.identities[0].UserID != result.User.ID {
+		t.Fatalf("identity not linked to the new user: %+v", setup.repo.identities)
+	}
+}
+
+func TestCallbackLogsInExistingIdentity(t *testing.T) {
+	user := users.User{ID: uuid.New(), Username: "laia", Email: "laia@example.com", IsActive: true, Role: roles.RoleCustomer}
+	setup := newTestSetup(t, user)
+	setup.repo.identities = []Identity{{ID: uuid.New(), UserID: user.ID, Provider: "mock", Subject: "sub-1"}}
+
+	result, err := setup.login(t, verifiedClaims("sub-1", "other@example.com"))
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if result.User.ID != user.ID {
+		t.Fatalf("logged in as %s, want %s", result.User.ID, user.ID)
+	}
+	if len(setup.customers.created) != 0 || len(setup.repo.identities) != 1 {
+		t.Fatal("an existing identity created a new customer or identity")
+	}
+}
+
+func TestCallbackLinksVerifiedCustomerByEmail(t *testing.T) {
+	verifiedAt := time.Now()
+	user := users.User{ID: uuid.New(), Username: "laia", Email: "laia@example.com", IsActive: true, Role: roles.RoleCustomer, EmailVerifiedAt: &verifiedAt}
+	setup := newTestSetup(t, user)
+
+	result, err := setup.login(t, verifiedClaims("sub-1", "LAIA@example.com"))
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if result.User.ID != user.ID || len(setup.customers.created) != 0 {
+		t.Fatalf("logged in as %s, want the existing customer %s", result.User.ID, user.ID)
+	}
+}
+
+func TestCallbackRefusesUnverifiedEmails(t *testing.T) {
+	verifiedAt := time.Now()
+	tests := []struct {
+		name   string
+		user   *users.User
+		claims jwt.MapClaims
+		want   error
+	}{
+		{
+			name:   "not verified by the provider",
+			claims: jwt.MapClaims{"sub": "sub-1", "email": "laia@example.com", "email_verified": false},
+			want:   ErrEmailNotVerified,
+		},
+		{
+			name:   "not verified by the local account",
+			user:   &users.User{ID: uuid.New(), Username: "attacker", Email: "laia@example.com", IsActive: true, Role: roles.RoleCustomer},
+			claims: verifiedClaims("sub-1", "laia@example.com"),
+			want:   ErrLinkRequired,
+		},
+		{
+			name:   "staff account",
+			user:   &users.User{ID: uuid.New(), Username: "trainer", Email: "laia@example.com", IsActive: true, Role: roles.RoleTrainer, EmailVerifiedAt: &verifiedAt},
+			claims: verifiedClaims("sub-1", "laia@example.com"),
+			want:   ErrLinkRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var existing []users.User
+			if tt.user != nil {
+				existing = append(existing, *tt.user)
+			}
+			setup := newTestSetup(t, existing...)
+
+			_, err := setup.login(t, tt.claims)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Callback error = %v, want %v", err, tt.want)
+			}
+			if len(setup.repo.identities) != 0 || len(setup.customers.created) != 0 {
+				t.Fatal("a refused login linked an identity or created a customer")
+			}
+		})
+	}
+}
+
+func TestCallbackLinksIdentityFromAccount(t *testing.T) {
+	user := users.User{ID: uuid.New(), Username: "trainer", Email: "trainer@example.com", IsActive: true, Role: roles.RoleTrainer}
+	setup := newTestSetup(t, user)
+
+	authorizationURL, err := setup.service.LinkURL(context.Background(), "mock", user.ID.String())
+	if err != nil {
+		t.Fatalf("LinkURL: %v", err)
+	}
+	state := setup.issuer.authorize(t, authorizationURL, verifiedClaims("sub-1", "personal@example.com"))
+	result, err := setup.service.Callback(context.Background(), "mock", CallbackRequest{Code: "code", State: state}, sessions.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if result.User.ID != user.ID || len(setup.repo.identities) != 1 || setup.repo.identities[0].UserID != user.ID {
+		t.Fatalf("identity not linked to the account that started the login: %+v", setup.repo.identities)
+	}
+}
+
+func TestCallbackRejectsWrongCodeVerifier(t *testing.T) {
+	setup := newTestSetup(t)
+
+	authorizationURL, err := setup.service.AuthorizationURL(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	state := setup.issuer.authorize(t, authorizationURL, verifiedClaims("sub-1", "laia@example.com"))
+	setup.issuer.challenge = "another-challenge"
+
+	_, err = setup.service.Callback(context.Background(), "mock", CallbackRequest{Code: "code", State: state}, sessions.ClientInfo{})
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("Callback error = %v, want %v", err, ErrExchangeFailed)
+	}
+}
@@ -0,0 +1,56 @@
+// Package testutil agrupa les comprovacions que comparteixen els tests de
+// diversos paquets. Només l'han d'importar els fitxers _test.go
+package testutil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// secretKeys són els camps que no poden sortir mai en una resposta de l'API
+var secretKeys = []string{"password", "password_hash", "hash", "secret_hash"}
+
+// hashPrefixes identifiquen un hash bcrypt, sigui quin sigui el camp on surti
+var hashPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+// AssertNoSecrets falla si el JSON de la resposta té algun camp de contrasenya
+// o algun hash bcrypt
+func AssertNoSecrets(t *testing.T, body []byte) {
+	t.Helper()
+	for _, prefix := range hashPrefixes {
+		if strings.Contains(string(body), prefix) {
+			t.Fatalf("response contains a password hash: %s", body)
+		}
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if key, ok := findSecretKey(decoded); ok {
+		t.Fatalf("response has a %q field: %s", key, body)
+	}
+}
+
+func findSecretKey(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			for _, secret := range secretKeys {
+				if strings.EqualFold(key, secret) {
+					return key, true
+				}
+			}
+			if found, ok := findSecretKey(nested); ok {
+				return found, true
+			}
+		}
+	case []interface{}:
+		for _, nested := range v {
+			if found, ok := findSecretKey(nested); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
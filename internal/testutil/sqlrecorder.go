@@ -0,0 +1,85 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Recorder és una base de dades falsa que apunta les sentències que rep, amb
+// els espais compactats. Les que contenen "fail" fallen, com si la base de
+// dades les rebutgés, i les consultes no retornen cap fila
+type Recorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+// NewDB obre una connexió a un Recorder nou que es tanca en acabar el test
+func NewDB(t *testing.T) (*sql.DB, *Recorder) {
+	t.Helper()
+	r := &Recorder{}
+	db := sql.OpenDB(r)
+	t.Cleanup(func() { db.Close() })
+	return db, r
+}
+
+// Log retorna les sentències rebudes fins ara, en ordre
+func (r *Recorder) Log() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.statements...)
+}
+
+func (r *Recorder) record(statement string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, strings.Join(strings.Fields(statement), " "))
+	if strings.Contains(statement, "fail") {
+		return errors.New("statement failed")
+	}
+	return nil
+}
+
+func (r *Recorder) Connect(ctx context.Context) (driver.Conn, error) { return &recorderConn{r}, nil }
+func (r *Recorder) Driver() driver.Driver                            { return nil }
+
+type recorderConn struct{ r *Recorder }
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recorderConn) Close() error { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	c.r.record("BEGIN")
+	return &recorderTx{c.r}, nil
+}
+
+func (c *recorderConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.r.record(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recorderConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.r.record(query); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+type recorderTx struct{ r *Recorder }
+
+func (t *recorderTx) Commit() error   { t.r.record("COMMIT"); return nil }
+func (t *recorderTx) Rollback() error { t.r.record("ROLLBACK"); return nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
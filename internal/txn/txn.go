@@ -0,0 +1,114 @@
+package txn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DBTX és el que tenen en comú *sql.DB i *sql.Tx. Els repositoris hi fan les
+// consultes perquè es puguin executar dins d'una unitat de treball
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// UnitOfWork agrupa les operacions de diversos repositoris en una sola
+// transacció, que es confirma o es desfà sencera
+type UnitOfWork interface {
+	// Do executa fn amb un context que porta la transacció. Si fn retorna un
+	// error, o si el context ja en portava una i fn falla, es desfà el que ha fet
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitOfWork struct {
+	db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return Run(ctx, u.db, fn)
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx          *sql.Tx
+	savepoints  int
+	afterCommit []func(ctx context.Context)
+}
+
+// Run executa fn dins d'una transacció. Si el context ja en porta una, fn s'hi
+// afegeix amb un savepoint, de manera que si falla només es desfà la seva part
+// i la transacció de fora continua sent vàlida
+func Run(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) (err error) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.savepoint(ctx, fn)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	state := &txState{tx: tx}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, hook := range state.afterCommit {
+		hook(ctx)
+	}
+	return nil
+}
+
+func (s *txState) savepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+	hooks := len(s.afterCommit)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		s.afterCommit = s.afterCommit[:hooks]
+		if _, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// Conn retorna la transacció del context o, si no n'hi ha, la base de dades
+func Conn(ctx context.Context, db *sql.DB) DBTX {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return db
+}
+
+// AfterCommit executa fn quan es confirmi la transacció del context, o de
+// seguida si no n'hi ha. Serveix per enviar correus i altres efectes que no es
+// poden desfer només si les dades s'han desat. Si la transacció es desfà, fn
+// no s'executa
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
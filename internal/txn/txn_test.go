@@ -0,0 +1,129 @@
+package txn
+
+import (
+	"context"
+	"database/sql"
+	"perretes-api/internal/testutil"
+	"reflect"
+	"testing"
+)
+
+func exec(ctx context.Context, db *sql.DB, query string) error {
+	_, err := Conn(ctx, db).ExecContext(ctx, query)
+	return err
+}
+
+func assertLog(t *testing.T, r *testutil.Recorder, want ...string) {
+	t.Helper()
+	if got := r.Log(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestRunCommitsAndThenRunsHooks(t *testing.T) {
+	db, r := testutil.NewDB(t)
+	var hookLog []string
+
+	err := Run(context.Background(), db, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { hookLog = r.Log() })
+		return exec(ctx, db, "INSERT INTO users")
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertLog(t, r, "BEGIN", "INSERT INTO users", "COMMIT")
+	if !reflect.DeepEqual(hookLog, r.Log()) {
+		t.Fatalf("hook ran before the commit, statements seen: %q", hookLog)
+	}
+}
+
+func TestRunRollsBackAndSkipsHooksOnError(t *testing.T) {
+	db, r := testutil.NewDB(t)
+	hookRan := false
+
+	err := Run(context.Background(), db, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { hookRan = true })
+		if err := exec(ctx, db, "INSERT INTO users"); err != nil {
+			return err
+		}
+		return exec(ctx, db, "INSERT INTO customers fail")
+	})
+	if err == nil {
+		t.Fatal("Run: expected an error")
+	}
+	assertLog(t, r, "BEGIN", "INSERT INTO users", "INSERT INTO customers fail", "ROLLBACK")
+	if hookRan {
+		t.Fatal("after commit hook ran on rollback")
+	}
+}
+
+func TestNestedRunReleasesSavepoint(t *testing.T) {
+	db, r := testutil.NewDB(t)
+
+	err := Run(context.Background(), db, func(ctx context.Context) error {
+		return Run(ctx, db, func(ctx context.Context) error {
+			return exec(ctx, db, "INSERT INTO users")
+		})
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertLog(t, r, "BEGIN", "SAVEPOINT sp_1", "INSERT INTO users", "RELEASE SAVEPOINT sp_1", "COMMIT")
+}
+
+func TestNestedRunFailureOnlyUndoesSavepoint(t *testing.T) {
+	db, r := testutil.NewDB(t)
+	var hooks []string
+
+	err := Run(context.Background(), db, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { hooks = append(hooks, "outer") })
+		innerErr := Run(ctx, db, func(ctx context.Context) error {
+			AfterCommit(ctx, func(ctx context.Context) { hooks = append(hooks, "inner") })
+			return exec(ctx, db, "INSERT INTO users fail")
+		})
+		if innerErr == nil {
+			t.Error("nested Run: expected an error")
+		}
+		return exec(ctx, db, "INSERT INTO users")
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertLog(t, r, "BEGIN", "SAVEPOINT sp_1", "INSERT INTO users fail", "ROLLBACK TO SAVEPOINT sp_1", "INSERT INTO users", "COMMIT")
+	if !reflect.DeepEqual(hooks, []string{"outer"}) {
+		t.Fatalf("hooks = %q, want only the outer one", hooks)
+	}
+}
+
+func TestUnitOfWorkRollsBackWhenNestedFailureIsReturned(t *testing.T) {
+	db, r := testutil.NewDB(t)
+	uow := NewUnitOfWork(db)
+
+	err := uow.Do(context.Background(), func(ctx context.Context) error {
+		if err := exec(ctx, db, "INSERT INTO users"); err != nil {
+			return err
+		}
+		return uow.Do(ctx, func(ctx context.Context) error {
+			return exec(ctx, db, "INSERT INTO customers fail")
+		})
+	})
+	if err == nil {
+		t.Fatal("Do: expected an error")
+	}
+	assertLog(t, r, "BEGIN", "INSERT INTO users", "SAVEPOINT sp_1", "INSERT INTO customers fail",
+		"ROLLBACK TO SAVEPOINT sp_1", "ROLLBACK")
+}
+
+func TestAfterCommitWithoutTransactionRunsImmediately(t *testing.T) {
+	db, r := testutil.NewDB(t)
+	hookRan := false
+
+	AfterCommit(context.Background(), func(ctx context.Context) { hookRan = true })
+	if !hookRan {
+		t.Fatal("hook didn't run without a transaction")
+	}
+	if err := exec(context.Background(), db, "INSERT INTO users"); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	assertLog(t, r, "INSERT INTO users")
+}
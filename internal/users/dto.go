@@ -1,5 +1,11 @@
 package users
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -8,7 +14,11 @@ type LoginRequest struct {
 type UserRequest struct {
 	Username   string `json:"username" binding:"required"`
 	Password   string `json:"password" binding:"required"`
-	IsCustomer *bool  `json:"is_customer" binding:"required"`
+	Role       string `json:"role"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	// EmailVerified el fan servir els altres serveis quan el correu ja s'ha
+	// verificat per un altre camí, com un proveïdor d'identitat extern
+	EmailVerified bool `json:"-"`
 }
 
 type ChangePasswordRequest struct {
@@ -16,7 +26,51 @@ type ChangePasswordRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ChangeOwnPasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 type LoginResponse struct {
-	User  User   `json:"user"`
+	User  UserResponse `json:"user"`
 	Token string `json:"token"`
-}
\ No newline at end of file
+}
+
+// ListUsersRequest són els paràmetres de GET /users. sort accepta username o
+// email, amb un - al davant per ordenar de manera descendent
+type ListUsersRequest struct {
+	Cursor     string `form:"cursor"`
+	Limit      int    `form:"limit"`
+	IsActive   *bool  `form:"is_active"`
+	IsCustomer *bool  `form:"is_customer"`
+	Username   string `form:"username"`
+	Sort       string `form:"sort"`
+}
+
+// UserResponse és l'usuari que es retorna a l'API, sense la contrasenya
+type UserResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	IsActive          bool       `json:"is_active"`
+	Role              string     `json:"role"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+}
+
+func NewUserResponse(user User) UserResponse {
+	return UserResponse{
+		ID:                user.ID,
+		Username:          user.Username,
+		Email:             user.Email,
+		IsActive:          user.IsActive,
+		Role:              user.Role,
+		PasswordChangedAt: user.PasswordChangedAt,
+		EmailVerifiedAt:   user.EmailVerifiedAt,
+	}
+}
+
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
@@ -3,9 +3,17 @@ package users
 import "errors"
 
 var (
-	ErrUserNotFound   = errors.New("user not found")
-	ErrInvalidID      = errors.New("invalid user ID")	
-	ErrUsernameTaken  = errors.New("username already taken")
-	ErrInvalidRequest = errors.New("invalid request")
-	ErrInactiveUser   = errors.New("inactive user")
-)
\ No newline at end of file
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidID       = errors.New("invalid user ID")
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrInvalidUsername = errors.New("username can't contain @")
+	ErrEmailTaken      = errors.New("email already taken")
+	ErrEmailRequired   = errors.New("email is required to register")
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrInactiveUser    = errors.New("inactive user")
+	ErrWrongPassword   = errors.New("current password is incorrect")
+	ErrUserErased      = errors.New("user has been erased")
+	ErrEraseSelf       = errors.New("you can't erase your own user")
+	ErrInvalidSort     = errors.New("invalid sort, use username, -username, email or -email")
+	ErrInvalidLimit    = errors.New("limit must be between 1 and 100")
+)
@@ -1,17 +1,51 @@
 package users
 
 import (
+	"errors"
 	"net/http"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/roles"
+	"perretes-api/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
-	userService UserService
+	userService  UserService
+	requireEmail bool
 }
 
-func NewUserHandler(userService UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+// NewUserHandler crea el handler d'usuaris. requireEmail fa obligatori el correu
+// al registre públic, perquè si cal verificar-lo un compte sense correu no podria
+// entrar mai
+func NewUserHandler(userService UserService, requireEmail bool) *UserHandler {
+	return &UserHandler{userService: userService, requireEmail: requireEmail}
+}
+
+// Register crea un usuari des de la ruta pública, sempre amb rol de client
+func (h *UserHandler) Register(c *gin.Context) {
+	var request UserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Role = roles.RoleCustomer
+	if h.requireEmail && request.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrEmailRequired.Error()})
+		return
+	}
+
+	user, err := h.userService.Create(c.Request.Context(), request)
+	if err != nil {
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
 }
 
 func (h *UserHandler) Create(c *gin.Context) {	
@@ -23,13 +57,39 @@ func (h *UserHandler) Create(c *gin.Context) {
 
 	user, err := h.userService.Create(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, user)
 }
 
+// List retorna els usuaris paginats per cursor. La resposta porta next_cursor
+// mentre quedin pàgines
+func (h *UserHandler) List(c *gin.Context) {
+	var request ListUsersRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.List(c.Request.Context(), request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidSort), errors.Is(err, ErrInvalidLimit), errors.Is(err, utils.ErrInvalidCursor):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *UserHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var request UserRequest
@@ -40,7 +100,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 
 	user, err := h.userService.Update(c.Request.Context(), id, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -58,6 +118,40 @@ func (h *UserHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func (h *UserHandler) Reactivate(c *gin.Context) {
+	user, err := h.userService.Reactivate(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Erase anonimitza l'usuari i les seves dades personals. Les estadístiques de
+// les inscripcions es mantenen
+func (h *UserHandler) Erase(c *gin.Context) {
+	if err := h.userService.Erase(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrEraseSelf), errors.Is(err, ErrInvalidUsername):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrUserErased):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	var request ChangePasswordRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -67,7 +161,14 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 	user, err := h.userService.ChangePassword(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if passwordpolicy.RespondInvalid(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, authz.ErrForbidden) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,75 @@
+package users_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/testutil"
+	"perretes-api/internal/users"
+	"perretes-api/internal/users/userstest"
+	"perretes-api/middleware"
+	"strings"
+	"testing"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type noopVerifier struct{}
+
+func (noopVerifier) SendVerification(ctx context.Context, user users.User, email string) error {
+	return nil
+}
+
+// Les respostes surten del servei real, que llegeix els usuaris amb el hash
+// de la contrasenya com ho fa el repositori de veritat
+func TestUserHandlerResponsesHidePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	admin := userstest.NewUser("admin", "admin@example.com", "Admin-Gos-7341", roles.RoleAdmin)
+	laia := userstest.NewUser("laia", "laia@example.com", "Laia-Gos-7341", roles.RoleCustomer)
+	repo := userstest.NewRepository(admin, laia)
+	handler := users.NewUserHandler(users.NewUserService(repo, noopVerifier{}, passwordpolicy.Policy{HistorySize: 3}), true)
+
+	router := gin.New()
+	// Fa el paper del middleware JWT amb un administrador autenticat
+	router.Use(func(c *gin.Context) {
+		c.Set("JWT_PAYLOAD", jwt.MapClaims{
+			middleware.IdentityKey: admin.ID.String(),
+			"role":                 roles.RoleAdmin,
+			"permissions":          []interface{}{roles.PermUsersWrite},
+		})
+		c.Next()
+	}, middleware.SetCaller())
+	router.POST("/register", handler.Register)
+	users.RegisterRoutes(router.Group(""), handler)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"register", http.MethodPost, "/register", `{"username":"pau","password":"Pau-Gos-7341","email":"pau@example.com"}`, http.StatusCreated},
+		{"create", http.MethodPost, "/users", `{"username":"marta","password":"Marta-Gos-7341","role":"trainer"}`, http.StatusCreated},
+		{"list", http.MethodGet, "/users", "", http.StatusOK},
+		{"update", http.MethodPut, "/users/" + laia.ID.String(), `{"username":"laia.puig","password":"unused"}`, http.StatusOK},
+		{"reactivate", http.MethodPost, "/users/" + laia.ID.String() + "/reactivate", "", http.StatusOK},
+		{"change password", http.MethodPost, "/users/change-password", `{"id":"` + laia.ID.String() + `","password":"Laia-Nou-9182"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			testutil.AssertNoSecrets(t, rec.Body.Bytes())
+		})
+	}
+}
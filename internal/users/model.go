@@ -6,11 +6,28 @@ import (
 	"github.com/google/uuid"
 )
 
+// User és l'usuari del domini, amb el hash de la contrasenya. No s'ha de
+// retornar a l'API: per això hi ha UserResponse
 type User struct {
 	ID       uuid.UUID `json:"id" db:"id"`
 	Username string    `json:"username" db:"username"`
-	Password string    `json:"password" db:"password"`	
+	Email    string    `json:"email" db:"email"`
+	Password string    `json:"-" db:"password"`	
 	IsActive bool `json:"is_active" db:"is_active"`	
-	IsCustomer bool `json:"is_customer" db:"is_customer"`
+	Role     string    `json:"role" db:"role"`
 	PasswordChangedAt *time.Time `json:"password_changed_at" db:"password_changed_at"`
-}
\ No newline at end of file
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+}
+
+// UserFilter són els filtres i la posició d'una pàgina del llistat d'usuaris.
+// La pàgina comença després de l'usuari amb AfterKey i AfterID en l'ordre triat
+type UserFilter struct {
+	IsActive       *bool
+	IsCustomer     *bool
+	UsernamePrefix string
+	SortField      string
+	SortDesc       bool
+	AfterKey       string
+	AfterID        uuid.UUID
+	Limit          int
+}
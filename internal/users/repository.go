@@ -4,6 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"perretes-api/internal/loginguard"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/txn"
+	"perretes-api/utils"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -15,7 +21,15 @@ type UserRepository interface {
 	ChangePassword(ctx context.Context, request ChangePasswordRequest) (User, error)	
 	FindByID(ctx context.Context, id uuid.UUID) (User, error)
 	FindByUsername(ctx context.Context, username string) (User, error)		
-	FindAll(ctx context.Context) ([]User, error)	
+	FindByEmail(ctx context.Context, email string) (User, error)
+	FindByLogin(ctx context.Context, login string) (User, error)
+	ChangeEmail(ctx context.Context, user User) error
+	AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, keep int) error
+	RecentPasswordHashes(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	List(ctx context.Context, filter UserFilter) ([]User, error)
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	Reactivate(ctx context.Context, id uuid.UUID) error
+	Erase(ctx context.Context, id uuid.UUID) error
 }
 
 type userRepository struct {
@@ -26,11 +40,16 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db}
 }
 
+// conn retorna la transacció de la unitat de treball del context, si n'hi ha
+func(r *userRepository) conn(ctx context.Context) txn.DBTX {
+	return txn.Conn(ctx, r.db)
+}
+
 func (r *userRepository) Create(ctx context.Context, user User) (User, error) {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO users (id, username, password, is_active, is_customer)
-		VALUES ($1, $2, $3, $4, $5)`,
-		user.ID, user.Username, user.Password, user.IsActive, user.IsCustomer,
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		INSERT INTO users (id, username, password, is_active, role_id, email_verified_at, email)
+		VALUES ($1, $2, $3, $4, (SELECT id FROM roles WHERE name = $5), $6, NULLIF($7, ''))`,
+		user.ID, user.Username, user.Password, user.IsActive, user.Role, user.EmailVerifiedAt, user.Email,
     )
     if err != nil {
         return User{}, fmt.Errorf("error inserting user: %w", err)
@@ -39,11 +58,11 @@ func (r *userRepository) Create(ctx context.Context, user User) (User, error) {
 }
 
 func(r *userRepository) Update(ctx context.Context, user User) (User, error) {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := r.conn(ctx).ExecContext(ctx, `
 		UPDATE users
-		SET username = $1, is_active = $2, is_customer = $3
+		SET username = $1, is_active = $2, role_id = (SELECT id FROM roles WHERE name = $3)
 		WHERE id = $4`,
-		user.Username, user.IsActive, user.IsCustomer, user.ID,
+		user.Username, user.IsActive, user.Role, user.ID,
 	)
 	if err != nil {
 		return User{}, fmt.Errorf("error updating user: %w", err)
@@ -52,7 +71,7 @@ func(r *userRepository) Update(ctx context.Context, user User) (User, error) {
 }
 
 func(r *userRepository) Delete(ctx context.Context, id uuid.UUID) (error) {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := r.conn(ctx).ExecContext(ctx, `
 		UPDATE users
 		SET is_active = false
 		WHERE id = $1`,
@@ -63,14 +82,29 @@ func(r *userRepository) Delete(ctx context.Context, id uuid.UUID) (error) {
 	return nil
 }
 
+// ChangePassword canvia la contrasenya i revoca totes les sessions de l'usuari,
+// de manera que els refresh tokens anteriors ja no serveixen
 func(r *userRepository) ChangePassword(ctx context.Context, request ChangePasswordRequest) (User, error){
-	_, err := r.db.ExecContext(ctx, `
-		UPDATE users
-		SET password = $1, password_changed_at = now()
-		WHERE id = $2`,
-		request.Password, request.ID)
+	err := txn.Run(ctx, r.db, func(ctx context.Context) error {
+		_, err := r.conn(ctx).ExecContext(ctx, `
+			UPDATE users
+			SET password = $1, password_changed_at = now()
+			WHERE id = $2`,
+			request.Password, request.ID)
+		if err != nil {
+			return fmt.Errorf("error changing password: %w", err)
+		}
+		_, err = r.conn(ctx).ExecContext(ctx, `
+			UPDATE sessions SET revoked_at = now()
+			WHERE user_id = $1 AND revoked_at IS NULL`,
+			request.ID)
+		if err != nil {
+			return fmt.Errorf("error revoking sessions: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return User{}, fmt.Errorf("error changing password: %w", err)
+		return User{}, err
 	}
 	strId, err  := uuid.Parse(request.ID)
 	if err != nil {
@@ -86,9 +120,9 @@ func(r *userRepository) ChangePassword(ctx context.Context, request ChangePasswo
 
 func(r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (User, error){
 	var user User
-	row := r.db.QueryRowContext(ctx, `SELECT id, username, password, is_active, is_customer, password_changed_at FROM users WHERE id = $1`, id)
+	row := r.conn(ctx).QueryRowContext(ctx, `SELECT u.id, u.username, COALESCE(u.email, ''), u.password, u.is_active, r.name, u.password_changed_at, u.email_verified_at FROM users u JOIN roles r ON r.id = u.role_id WHERE u.id = $1`, id)
 	
-	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.IsActive, &user.IsCustomer, &user.PasswordChangedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.Role, &user.PasswordChangedAt, &user.EmailVerifiedAt)
 	if err == sql.ErrNoRows {
 		return User{}, ErrUserNotFound
 	}else if err != nil {
@@ -102,9 +136,54 @@ func(r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (User, error
 
 func(r *userRepository) FindByUsername(ctx context.Context, username string) (User, error)	{
 	var user User
-	row := r.db.QueryRowContext(ctx, `SELECT id, username, password, is_active, is_customer, password_changed_at FROM users WHERE username = $1`, username)
+	row := r.conn(ctx).QueryRowContext(ctx, `SELECT u.id, u.username, COALESCE(u.email, ''), u.password, u.is_active, r.name, u.password_changed_at, u.email_verified_at FROM users u JOIN roles r ON r.id = u.role_id WHERE u.username = $1`, username)
+	
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.Role, &user.PasswordChangedAt, &user.EmailVerifiedAt)
+	if err == sql.ErrNoRows {
+		return User{}, ErrUserNotFound
+	}else if err != nil {
+		return User{}, fmt.Errorf("error getting user: %w", err)
+	}
+	if !user.IsActive {
+		return User{}, ErrInactiveUser
+	}
+	return user, nil
+}
+
+
+
+// FindByEmail busca l'usuari pel correu sense distingir majúscules
+func(r *userRepository) FindByEmail(ctx context.Context, email string) (User, error)	{
+	var user User
+	row := r.conn(ctx).QueryRowContext(ctx, `SELECT u.id, u.username, COALESCE(u.email, ''), u.password, u.is_active, r.name, u.password_changed_at, u.email_verified_at FROM users u JOIN roles r ON r.id = u.role_id WHERE lower(u.email) = lower($1)`, email)
+	
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.Role, &user.PasswordChangedAt, &user.EmailVerifiedAt)
+	if err == sql.ErrNoRows {
+		return User{}, ErrUserNotFound
+	}else if err != nil {
+		return User{}, fmt.Errorf("error getting user: %w", err)
+	}
+	if !user.IsActive {
+		return User{}, ErrInactiveUser
+	}
+	return user, nil
+}
+
+// FindByLogin busca l'usuari pel nom d'usuari o pel correu. Un login amb @ es
+// busca pel correu i, només si cap usuari el té, pel nom d'usuari
+func(r *userRepository) FindByLogin(ctx context.Context, login string) (User, error)	{
+	// Els noms d'usuari nous no poden portar @, així ningú no pot registrar un
+	// nom d'usuari igual al correu d'un altre. Els comptes antics amb un correu
+	// com a nom d'usuari i sense correu propi encara poden entrar
+	condition := "u.username = $1"
+	if strings.Contains(login, "@") {
+		condition = `lower(u.email) = lower($1)
+			OR (u.username = $1 AND NOT EXISTS (SELECT 1 FROM users e WHERE lower(e.email) = lower($1)))`
+	}
+	var user User
+	row := r.conn(ctx).QueryRowContext(ctx, `SELECT u.id, u.username, COALESCE(u.email, ''), u.password, u.is_active, r.name, u.password_changed_at, u.email_verified_at FROM users u JOIN roles r ON r.id = u.role_id WHERE `+condition, login)
 	
-	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.IsActive, &user.IsCustomer, &user.PasswordChangedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.Role, &user.PasswordChangedAt, &user.EmailVerifiedAt)
 	if err == sql.ErrNoRows {
 		return User{}, ErrUserNotFound
 	}else if err != nil {
@@ -116,22 +195,275 @@ func(r *userRepository) FindByUsername(ctx context.Context, username string) (Us
 	return user, nil
 }
 
+// userSortColumns són les columnes per les quals es pot ordenar el llistat
+var userSortColumns = map[string]string{
+	"username": "u.username",
+	"email":    "COALESCE(u.email, '')",
+}
+
+// List retorna una pàgina d'usuaris. Es pagina per cursor (valor de la columna
+// d'ordenació i ID) perquè les pàgines no es moguin quan s'afegeixen usuaris
+func(r *userRepository) List(ctx context.Context, filter UserFilter) ([]User, error) {
+	column, ok := userSortColumns[filter.SortField]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+	direction, comparison := "ASC", ">"
+	if filter.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{"true"}
+	args := []interface{}{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.IsActive != nil {
+		conditions = append(conditions, "u.is_active = "+addArg(*filter.IsActive))
+	}
+	if filter.IsCustomer != nil {
+		operator := "="
+		if !*filter.IsCustomer {
+			operator = "<>"
+		}
+		conditions = append(conditions, "r.name "+operator+" "+addArg(roles.RoleCustomer))
+	}
+	if filter.UsernamePrefix != "" {
+		conditions = append(conditions, "lower(u.username) LIKE lower("+addArg(utils.EscapeLike(filter.UsernamePrefix))+") || '%'")
+	}
+	if filter.AfterID != uuid.Nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, u.id) %s (%s, %s)", column, comparison, addArg(filter.AfterKey), addArg(filter.AfterID)))
+	}
 
+	query := fmt.Sprintf(`
+		SELECT u.id, u.username, COALESCE(u.email, ''), u.password, u.is_active, r.name, u.password_changed_at, u.email_verified_at
+		FROM users u JOIN roles r ON r.id = u.role_id
+		WHERE %s
+		ORDER BY %s %s, u.id %s
+		LIMIT %s`,
+		strings.Join(conditions, " AND "), column, direction, direction, addArg(filter.Limit))
 
-func(r *userRepository) FindAll(ctx context.Context) ([]User, error){
-	var users []User
-	rows, err := r.db.QueryContext(ctx, `SELECT id, username, password, is_active, is_customer, password_changed_at FROM users`)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error getting users: %w", err)
 	}
 	defer rows.Close()
+
+	users := []User{}
 	for rows.Next() {
 		var user User
-		err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.IsActive, &user.IsCustomer, &user.PasswordChangedAt)
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.Role, &user.PasswordChangedAt, &user.EmailVerifiedAt)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning user: %w", err)
 		}
 		users = append(users, user)
 	}
-	return users, nil
-}
\ No newline at end of file
+	return users, rows.Err()
+}
+
+func(r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE users
+		SET email_verified_at = now()
+		WHERE id = $1 AND email_verified_at IS NULL`,
+		id)
+	if err != nil {
+		return fmt.Errorf("error verifying user email: %w", err)
+	}
+	return nil
+}
+
+// ChangeEmail canvia el correu de l'usuari i l'estat de verificació
+func(r *userRepository) ChangeEmail(ctx context.Context, user User) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE users
+		SET email = NULLIF($1, ''), email_verified_at = $2
+		WHERE id = $3`,
+		user.Email, user.EmailVerifiedAt, user.ID)
+	if err != nil {
+		return fmt.Errorf("error changing user email: %w", err)
+	}
+	return nil
+}
+
+// AddPasswordHistory desa el hash de la contrasenya nova i esborra les entrades
+// més antigues que les últimes keep
+func(r *userRepository) AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, keep int) error {
+	return txn.Run(ctx, r.db, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO password_history (user_id, password_hash)
+			VALUES ($1, $2)`,
+			userID, passwordHash)
+		if err != nil {
+			return fmt.Errorf("error inserting password history: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM password_history
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history WHERE user_id = $1
+				ORDER BY created_at DESC LIMIT $2
+			)`,
+			userID, keep)
+		if err != nil {
+			return fmt.Errorf("error trimming password history: %w", err)
+		}
+		return nil
+	})
+}
+
+// RecentPasswordHashes retorna els hashes de les últimes contrasenyes, de la més nova a la més antiga
+func(r *userRepository) RecentPasswordHashes(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting password history: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("error scanning password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// Reactivate torna a activar un usuari desactivat i la seva fitxa de client. Un
+// usuari esborrat no es pot reactivar
+func(r *userRepository) Reactivate(ctx context.Context, id uuid.UUID) error {
+	return txn.Run(ctx, r.db, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		if _, err := r.lockForUpdate(ctx, tx, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE users SET is_active = true WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error reactivating user: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE customers SET is_active = true WHERE user_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error reactivating customer: %w", err)
+		}
+		return nil
+	})
+}
+
+// Erase anonimitza l'usuari, la seva fitxa de client i les dades que ha enviat
+// a l'API que hi ha a action_logs, i n'esborra les credencials. Les inscripcions
+// i el progrés es mantenen, lligats a un usuari que ja no identifica ningú. Si
+// el context porta una unitat de treball, s'hi afegeix
+func(r *userRepository) Erase(ctx context.Context, id uuid.UUID) error {
+	return txn.Run(ctx, r.db, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		user, err := r.lockForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE action_logs SET metadata = '{}'::jsonb WHERE user_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing action logs: %w", err)
+		}
+		// Les peticions de /auth es guarden sense usuari però amb el nom o el
+		// correu que es va enviar. Dels logins fallits es manté el motiu
+		_, err = tx.ExecContext(ctx, `
+			UPDATE action_logs
+			SET metadata = CASE WHEN action_type = $1 THEN metadata - 'username' - 'ip' ELSE '{}'::jsonb END
+			WHERE lower(metadata->>'username') IN (lower($2), lower(NULLIF($3, '')))
+				OR lower(metadata->>'email') = lower(NULLIF($3, ''))
+				OR lower(metadata->>'email') IN (SELECT lower(email) FROM customers WHERE user_id = $4 AND email <> '')`,
+			loginguard.ActionFailedLogin, user.Username, user.Email, id)
+		if err != nil {
+			return fmt.Errorf("error erasing anonymous action logs: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM login_throttles
+			WHERE (scope = $1 AND key IN (lower($2), lower(NULLIF($3, ''))))
+				OR (scope = $4 AND key = lower(NULLIF($3, '')))`,
+			loginguard.ScopeUsername, user.Username, user.Email, loginguard.ScopeMagicLinkEmail)
+		if err != nil {
+			return fmt.Errorf("error erasing login throttles: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE customers
+			SET name = 'Erased', surname = '', phone_number = '', email = '', is_active = false
+			WHERE user_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing customer: %w", err)
+		}
+		// Els gossos es mantenen per a les inscripcions, però sense les dades que
+		// permeten identificar-ne el propietari
+		_, err = tx.ExecContext(ctx, `
+			UPDATE dogs
+			SET microchip_number = NULL, photo_url = NULL, behaviour_notes = NULL, is_active = false
+			WHERE customer_id IN (SELECT id FROM customers WHERE user_id = $1)`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing dogs: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE dog_health_records
+			SET veterinarian = NULL, notes = NULL
+			WHERE dog_id IN (
+				SELECT d.id FROM dogs d JOIN customers c ON c.id = d.customer_id WHERE c.user_id = $1
+			)`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing dog health records: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE training_diary_entries
+			SET notes = NULL, attachment_url = NULL, attachment_type = NULL
+			WHERE enrollment_id IN (SELECT id FROM course_enrollments WHERE user_id = $1)`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing training diary: %w", err)
+		}
+
+		for _, table := range []string{
+			"sessions", "refresh_tokens", "api_keys", "identities", "user_mfa", "mfa_recovery_codes",
+			"password_history", "password_reset_tokens", "email_verification_tokens", "magic_link_tokens",
+		} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id); err != nil {
+				return fmt.Errorf("error erasing %s: %w", table, err)
+			}
+		}
+
+		// Un hash buit no coincideix mai amb cap contrasenya
+		_, err = tx.ExecContext(ctx, `
+			UPDATE users
+			SET username = 'erased-' || id::text, email = NULL, password = '', is_active = false,
+				email_verified_at = NULL, password_changed_at = now(), erased_at = now()
+			WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error erasing user: %w", err)
+		}
+		return nil
+	})
+}
+
+// lockForUpdate bloqueja l'usuari dins de la transacció, encara que estigui
+// inactiu. Retorna ErrUserErased si ja s'havia esborrat
+func(r *userRepository) lockForUpdate(ctx context.Context, tx txn.DBTX, id uuid.UUID) (User, error) {
+	var user User
+	var erasedAt *time.Time
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, username, COALESCE(email, ''), erased_at FROM users WHERE id = $1 FOR UPDATE`, id,
+	).Scan(&user.ID, &user.Username, &user.Email, &erasedAt)
+	if err == sql.ErrNoRows {
+		return User{}, ErrUserNotFound
+	} else if err != nil {
+		return User{}, fmt.Errorf("error getting user: %w", err)
+	}
+	if erasedAt != nil {
+		return User{}, ErrUserErased
+	}
+	return user, nil
+}
@@ -1,16 +1,26 @@
 package users
 
-import "github.com/gin-gonic/gin"
+import (
+	"perretes-api/internal/roles"
+	"perretes-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(router *gin.RouterGroup, handler *UserHandler) {
-	roles := router.Group("/users")
+	users := router.Group("/users")
 	{		
-		roles.PUT("/:id", handler.Update)
-		roles.DELETE("/:id", handler.Delete)
-		roles.POST("/change-password", handler.ChangePassword)				
+		users.GET("", middleware.RequirePermission(roles.PermUsersWrite), handler.List)
+		users.POST("", middleware.RequirePermission(roles.PermUsersWrite), handler.Create)
+		users.PUT("/:id", middleware.RequirePermission(roles.PermUsersWrite), handler.Update)
+		users.DELETE("/:id", middleware.RequirePermission(roles.PermUsersWrite), handler.Delete)
+		users.POST("/:id/reactivate", middleware.RequirePermission(roles.PermUsersWrite), handler.Reactivate)
+		users.POST("/:id/erase", middleware.RequirePermission(roles.PermUsersWrite), handler.Erase)
+		// Amb una clau d'API es podria prendre el compte canviant-ne la contrasenya
+		users.POST("/change-password", middleware.DenyAPIKeys(), handler.ChangePassword)				
 	}
 }
 
 func RegisterPublicRoutes(router *gin.RouterGroup, handler *UserHandler) {
-    router.POST("/register", handler.Create) // Ruta pública per crear usuaris
-}
\ No newline at end of file
+    router.POST("/register", handler.Register) // Ruta pública per crear usuaris
+}
@@ -5,101 +5,242 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"perretes-api/internal/authz"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/txn"
+	"perretes-api/utils"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// UserService retorna sempre UserResponse: el hash de la contrasenya no surt
+// mai del servei
 type UserService interface {
-	Create(ctx context.Context, request UserRequest) (User, error)
-	Update(ctx context.Context, id string, request UserRequest)(User, error)
+	Create(ctx context.Context, request UserRequest) (UserResponse, error)
+	Update(ctx context.Context, id string, request UserRequest)(UserResponse, error)
 	Delete(ctx context.Context, id string) (error)
-	ChangePassword(ctx context.Context, request ChangePasswordRequest) (User, error)	
-	FindByUsername(ctx context.Context, username string) (User, error)
-	FindByID(ctx context.Context, id string) (User, error)	
-	FindAll(ctx context.Context) ([]User, error)	
+	ChangePassword(ctx context.Context, request ChangePasswordRequest) (UserResponse, error)	
+	ChangeOwnPassword(ctx context.Context, id string, request ChangeOwnPasswordRequest) (UserResponse, error)
+	ResetPassword(ctx context.Context, id string, password string) (UserResponse, error)
+	ChangeEmail(ctx context.Context, id string, email string) (UserResponse, error)
+	FindByUsername(ctx context.Context, username string) (UserResponse, error)
+	FindByID(ctx context.Context, id string) (UserResponse, error)	
+	List(ctx context.Context, request ListUsersRequest) (UserListResponse, error)
+	Reactivate(ctx context.Context, id string) (UserResponse, error)
+	Erase(ctx context.Context, id string) error
+}
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+// EmailVerifier envia el correu de verificació quan es crea un usuari amb correu
+type EmailVerifier interface {
+	SendVerification(ctx context.Context, user User, email string) error
 }
 
 type userService struct {
 	repo UserRepository
+	verifier EmailVerifier
+	policy passwordpolicy.Policy
 }
 
-func NewUserService(repo UserRepository) UserService {
-	return &userService{repo}
+func NewUserService(repo UserRepository, verifier EmailVerifier, policy passwordpolicy.Policy) UserService {
+	return &userService{repo, verifier, policy}
 }
 
-func(s *userService) Create(ctx context.Context, request UserRequest) (User, error) {
+func(s *userService) Create(ctx context.Context, request UserRequest) (UserResponse, error) {
 	// Validate the request
 	if request.Username == "" || request.Password == ""  {
-		return User{} , ErrInvalidRequest
+		return UserResponse{} , ErrInvalidRequest
+	}
+	// El @ queda reservat als correus, que també serveixen per entrar
+	if strings.Contains(request.Username, "@") {
+		return UserResponse{}, ErrInvalidUsername
 	}
 
 	// Check if the username is already taken
 	_, err := s.repo.FindByUsername(ctx, request.Username)	
 	if err == nil {		
-		return User{}, ErrUsernameTaken
+		return UserResponse{}, ErrUsernameTaken
 	}
 
 	if err != ErrUserNotFound {		
-		return User{}, err
+		return UserResponse{}, err
+	}
+
+	if err := s.checkEmailAvailable(ctx, uuid.Nil, request.Email); err != nil {
+		return UserResponse{}, err
+	}
+
+	role := request.Role
+	if role == "" {
+		role = roles.RoleCustomer
+	}
+	if !roles.IsValid(role) {
+		return UserResponse{}, roles.ErrInvalidRole
+	}
+
+	if err := s.policy.Validate(request.Password, request.Username, nil); err != nil {
+		return UserResponse{}, err
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
     if err != nil {
-        return User{}, err
+        return UserResponse{}, err
     }
 	// Create a new User instance
 	now := time.Now()
 	user := User{
 		ID:       uuid.New(),
 		Username: request.Username,
+		Email: request.Email,
 		Password: string(hashedPassword),		
 		IsActive: true,		
-		IsCustomer: *request.IsCustomer,		
+		Role: role,
 		PasswordChangedAt: &now,		
 	}
+	// Els comptes de personal els crea un administrador i no cal verificar-los
+	if role != roles.RoleCustomer || request.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
 
 	// Insert the user into the database
 	createdUser, err := s.repo.Create(ctx, user)
 	if err != nil {
-		return User{}, err
+		return UserResponse{}, err
+	}
+	if err := s.repo.AddPasswordHistory(ctx, createdUser.ID, createdUser.Password, s.historySize()); err != nil {
+		return UserResponse{}, err
 	}
 
-	//Create a validation string store in redis and send it to the user via email or sms
+	// Si l'usuari es crea dins d'una unitat de treball, el correu s'envia quan es confirma
+	txn.AfterCommit(ctx, func(ctx context.Context) {
+		s.sendVerification(ctx, createdUser)
+	})
 
-	return createdUser, nil
+	return NewUserResponse(createdUser), nil
 }
 
-func(s *userService) Update(ctx context.Context,id string,  request UserRequest)(User, error){
+func(s *userService) Update(ctx context.Context,id string,  request UserRequest)(UserResponse, error){
 	if id == "" || request.Username == ""  {
-		return User{} , ErrInvalidRequest
+		return UserResponse{} , ErrInvalidRequest
 	}
 
 	existingUser, err := s.repo.FindByID(ctx, uuid.MustParse(id))
 	if err != nil && !errors.Is(err, ErrUserNotFound){
-		return User{}, fmt.Errorf("something went wrong getting the user")
+		return UserResponse{}, fmt.Errorf("something went wrong getting the user")
 	}
 	if errors.Is(err, ErrUserNotFound) {
-		return User{}, err
+		return UserResponse{}, err
+	}
+	// Els usuaris antics poden tenir un @ al nom i el poden mantenir, però no
+	// se'n pot posar un de nou
+	if request.Username != existingUser.Username && strings.Contains(request.Username, "@") {
+		return UserResponse{}, ErrInvalidUsername
 	}
 	if !existingUser.IsActive {
-		return User{}, ErrInactiveUser
+		return UserResponse{}, ErrInactiveUser
+	}
+	role := existingUser.Role
+	if request.Role != "" {
+		if !roles.IsValid(request.Role) {
+			return UserResponse{}, roles.ErrInvalidRole
+		}
+		role = request.Role
 	}
-	now := time.Now()
 	user := User{
 		ID:       uuid.MustParse(id),		
 		Username: request.Username,	
-		IsCustomer: *request.IsCustomer,			
-		PasswordChangedAt: &now,		
+		IsActive: existingUser.IsActive,
+		Role: role,
+		PasswordChangedAt: existingUser.PasswordChangedAt,
 	}
 
 	response, err := s.repo.Update(ctx, user)
 	if err != nil {
-		return User{}, err
+		return UserResponse{}, err
+	}
+	response.Email = existingUser.Email
+	response.EmailVerifiedAt = existingUser.EmailVerifiedAt
+
+	if request.Email != "" && !strings.EqualFold(request.Email, existingUser.Email) {
+		return s.ChangeEmail(ctx, id, request.Email)
+	}
+	return NewUserResponse(response), nil
+}
+
+// ChangeEmail canvia el correu amb què l'usuari pot entrar. El correu nou d'un
+// client s'ha de tornar a verificar
+func (s *userService) ChangeEmail(ctx context.Context, id string, email string) (UserResponse, error) {
+	if email == "" {
+		return UserResponse{}, ErrInvalidRequest
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return UserResponse{}, ErrInvalidID
+	}
+	user, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return UserResponse{}, err
+	}
+	if strings.EqualFold(user.Email, email) {
+		return NewUserResponse(user), nil
+	}
+	if err := s.checkEmailAvailable(ctx, user.ID, email); err != nil {
+		return UserResponse{}, err
+	}
+
+	user.Email = email
+	if user.Role == roles.RoleCustomer {
+		user.EmailVerifiedAt = nil
+	}
+	if err := s.repo.ChangeEmail(ctx, user); err != nil {
+		return UserResponse{}, err
+	}
+
+	txn.AfterCommit(ctx, func(ctx context.Context) {
+		s.sendVerification(ctx, user)
+	})
+	return NewUserResponse(user), nil
+}
+
+// checkEmailAvailable comprova que cap altre usuari, actiu o no, faci servir el correu
+func (s *userService) checkEmailAvailable(ctx context.Context, id uuid.UUID, email string) error {
+	if email == "" {
+		return nil
+	}
+	existing, err := s.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if errors.Is(err, ErrInactiveUser) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != id {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// sendVerification envia el correu de verificació si el correu de l'usuari no
+// està verificat. Si falla, l'usuari el pot tornar a demanar
+func (s *userService) sendVerification(ctx context.Context, user User) {
+	if user.Email == "" || s.verifier == nil || user.EmailVerifiedAt != nil {
+		return
+	}
+	if err := s.verifier.SendVerification(ctx, user, user.Email); err != nil {
+		log.Printf("Error sending verification email: %v", err)
 	}
-	response.IsCustomer = existingUser.IsCustomer	
-	return response, nil
 }
 
 func (s *userService) Delete(ctx context.Context, id string) error {
@@ -126,79 +267,229 @@ func (s *userService) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (s *userService) ChangePassword(ctx context.Context, request ChangePasswordRequest) (User, error) {
+// Reactivate torna a activar un usuari desactivat amb Delete
+func (s *userService) Reactivate(ctx context.Context, id string) (UserResponse, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return UserResponse{}, ErrInvalidID
+	}
+	if err := s.repo.Reactivate(ctx, parsedID); err != nil {
+		return UserResponse{}, err
+	}
+	user, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return UserResponse{}, err
+	}
+	return NewUserResponse(user), nil
+}
+
+// Erase anonimitza l'usuari per complir una petició de supressió (RGPD). No es
+// pot desfer
+func (s *userService) Erase(ctx context.Context, id string) error {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	if caller, ok := authz.CallerFromContext(ctx); ok && caller.ID == parsedID {
+		return ErrEraseSelf
+	}
+	return s.repo.Erase(ctx, parsedID)
+}
+
+func (s *userService) ChangePassword(ctx context.Context, request ChangePasswordRequest) (UserResponse, error) {
 	if request.ID == "" || request.Password == "" {
-		return User{}, ErrInvalidRequest
+		return UserResponse{}, ErrInvalidRequest
+	}
+	parsedID, err := uuid.Parse(request.ID)
+	if err != nil {
+		return UserResponse{}, ErrInvalidID
+	}
+	if err := authz.CheckOwner(ctx, parsedID, roles.PermUsersWrite); err != nil {
+		return UserResponse{}, err
 	}
 
-	existingUser, err := s.repo.FindByID(ctx, uuid.MustParse(request.ID))
+	return s.setPassword(ctx, parsedID, request.Password)
+}
+
+// ResetPassword canvia la contrasenya sense comprovar qui fa la petició. Només
+// s'ha de cridar quan la identitat ja s'ha verificat per un altre camí, com un token de restabliment
+func (s *userService) ResetPassword(ctx context.Context, id string, password string) (UserResponse, error) {
+	if id == "" || password == "" {
+		return UserResponse{}, ErrInvalidRequest
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return UserResponse{}, ErrInvalidID
+	}
+
+	return s.setPassword(ctx, parsedID, password)
+}
+
+func (s *userService) setPassword(ctx context.Context, id uuid.UUID, password string) (UserResponse, error) {
+	existingUser, err := s.repo.FindByID(ctx, id)
 	if err != nil && !errors.Is(err, ErrUserNotFound){
-		return User{}, fmt.Errorf("something went wrong getting the user")
+		return UserResponse{}, fmt.Errorf("something went wrong getting the user")
 	}
 	if errors.Is(err, ErrUserNotFound) {
-		return User{}, err
+		return UserResponse{}, err
 	}
 	if !existingUser.IsActive {
-		return User{}, ErrInactiveUser
+		return UserResponse{}, ErrInactiveUser
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	// La contrasenya actual sempre compta, encara que no sigui a l'historial
+	previous, err := s.repo.RecentPasswordHashes(ctx, id, s.policy.HistorySize)
 	if err != nil {
-		return User{}, err
+		return UserResponse{}, err
+	}
+	previous = append([]string{existingUser.Password}, previous...)
+	if err := s.policy.Validate(password, existingUser.Username, previous); err != nil {
+		return UserResponse{}, err
 	}
 
-	request.Password = string(hashedPassword)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return UserResponse{}, err
+	}
 
-	response, err := s.repo.ChangePassword(ctx, request)
+	response, err := s.repo.ChangePassword(ctx, ChangePasswordRequest{
+		ID:       id.String(),
+		Password: string(hashedPassword),
+	})
 	if err != nil {
-		return User{}, err
+		return UserResponse{}, err
 	}
-	return response, nil
+	if err := s.repo.AddPasswordHistory(ctx, id, string(hashedPassword), s.historySize()); err != nil {
+		return UserResponse{}, err
+	}
+	return NewUserResponse(response), nil
 }
 
-func (s *userService) FindByUsername(ctx context.Context, username string) (User, error) {
+// historySize és quantes contrasenyes es guarden a l'historial. Com a mínim la
+// darrera, que és l'actual
+func (s *userService) historySize() int {
+	if s.policy.HistorySize < 1 {
+		return 1
+	}
+	return s.policy.HistorySize
+}
+
+// ChangeOwnPassword canvia la contrasenya de l'usuari després de comprovar l'actual
+func (s *userService) ChangeOwnPassword(ctx context.Context, id string, request ChangeOwnPasswordRequest) (UserResponse, error) {
+	if request.CurrentPassword == "" || request.NewPassword == "" {
+		return UserResponse{}, ErrInvalidRequest
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return UserResponse{}, ErrInvalidID
+	}
+
+	existingUser, err := s.repo.FindByID(ctx, parsedID)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(request.CurrentPassword))
+	if err != nil {
+		return UserResponse{}, ErrWrongPassword
+	}
+
+	return s.ChangePassword(ctx, ChangePasswordRequest{
+		ID:       id,
+		Password: request.NewPassword,
+	})
+}
+
+func (s *userService) FindByUsername(ctx context.Context, username string) (UserResponse, error) {
 	if username == "" {
-		return User{}, ErrInvalidRequest
+		return UserResponse{}, ErrInvalidRequest
 	}
 
 	user, err := s.repo.FindByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return User{}, ErrUserNotFound
+			return UserResponse{}, ErrUserNotFound
 		}
-		return User{}, err
+		return UserResponse{}, err
 	}
 
-	return user, nil
+	return NewUserResponse(user), nil
 }
 
-func (s *userService) FindByID(ctx context.Context, id string) (User, error) {
+func (s *userService) FindByID(ctx context.Context, id string) (UserResponse, error) {
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
-		return User{}, ErrInvalidID
+		return UserResponse{}, ErrInvalidID
 	}
 
 	user, err := s.repo.FindByID(ctx, parsedID) // Usa l'ID validat
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return User{}, ErrUserNotFound
+			return UserResponse{}, ErrUserNotFound
 		}
-		return User{}, err
+		return UserResponse{}, err
 	}
 
-	return user, nil
+	return NewUserResponse(user), nil
 }
 
-func (s *userService) FindAll(ctx context.Context) ([]User, error) {
-	users, err := s.repo.FindAll(ctx)
-	if err != nil {
-		return nil, err
+// List retorna una pàgina del llistat d'usuaris sense les contrasenyes
+func (s *userService) List(ctx context.Context, request ListUsersRequest) (UserListResponse, error) {
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 1 || limit > maxListLimit {
+		return UserListResponse{}, ErrInvalidLimit
+	}
+
+	sort := request.Sort
+	if sort == "" {
+		sort = "username"
+	}
+	filter := UserFilter{
+		IsActive:       request.IsActive,
+		IsCustomer:     request.IsCustomer,
+		UsernamePrefix: strings.TrimSpace(request.Username),
+		SortField:      strings.TrimPrefix(sort, "-"),
+		SortDesc:       strings.HasPrefix(sort, "-"),
+		// Se'n demana un de més per saber si hi ha pàgina següent
+		Limit: limit + 1,
+	}
+	if _, ok := userSortColumns[filter.SortField]; !ok {
+		return UserListResponse{}, ErrInvalidSort
+	}
+
+	// El cursor porta l'ordre amb què s'ha generat perquè no es barregi amb un altre
+	if request.Cursor != "" {
+		values, err := utils.DecodeCursor(request.Cursor, 3)
+		if err != nil || values[0] != sort {
+			return UserListResponse{}, utils.ErrInvalidCursor
+		}
+		filter.AfterKey = values[1]
+		filter.AfterID, err = uuid.Parse(values[2])
+		if err != nil {
+			return UserListResponse{}, utils.ErrInvalidCursor
+		}
 	}
 
-	var Users []User
-	for _, user := range users {
-		Users = append(Users, user)
+	found, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return UserListResponse{}, err
 	}
 
-	return Users, nil
+	response := UserListResponse{Users: []UserResponse{}}
+	for i, user := range found {
+		if i == limit {
+			last := found[limit-1]
+			key := last.Username
+			if filter.SortField == "email" {
+				key = last.Email
+			}
+			response.NextCursor = utils.EncodeCursor(sort, key, last.ID.String())
+			break
+		}
+		response.Users = append(response.Users, NewUserResponse(user))
+	}
+	return response, nil
 }
@@ -0,0 +1,200 @@
+// Package userstest té un repositori d'usuaris en memòria perquè els tests
+// puguin fer servir el servei d'usuaris real sense base de dades
+package userstest
+
+import (
+	"context"
+	"perretes-api/internal/users"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ users.UserRepository = (*Repository)(nil)
+
+// Repository guarda els usuaris amb el hash de la contrasenya, com la taula users
+type Repository struct {
+	mu      sync.Mutex
+	users   map[uuid.UUID]users.User
+	history map[uuid.UUID][]string
+}
+
+func NewRepository(existing ...users.User) *Repository {
+	r := &Repository{users: map[uuid.UUID]users.User{}, history: map[uuid.UUID][]string{}}
+	for _, user := range existing {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+// NewUser retorna un usuari actiu amb el hash bcrypt de la contrasenya
+func NewUser(username, email, password, role string) users.User {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	return users.User{
+		ID:                uuid.New(),
+		Username:          username,
+		Email:             email,
+		Password:          string(hash),
+		IsActive:          true,
+		Role:              role,
+		PasswordChangedAt: &now,
+		EmailVerifiedAt:   &now,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, user users.User) (users.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *Repository) Update(ctx context.Context, user users.User) (users.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.users[user.ID]
+	if !ok {
+		return users.User{}, users.ErrUserNotFound
+	}
+	existing.Username = user.Username
+	existing.Role = user.Role
+	r.users[user.ID] = existing
+	return existing, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *users.User) { user.IsActive = false })
+}
+
+func (r *Repository) ChangePassword(ctx context.Context, request users.ChangePasswordRequest) (users.User, error) {
+	id, err := uuid.Parse(request.ID)
+	if err != nil {
+		return users.User{}, users.ErrInvalidID
+	}
+	now := time.Now()
+	if err := r.update(id, func(user *users.User) {
+		user.Password = request.Password
+		user.PasswordChangedAt = &now
+	}); err != nil {
+		return users.User{}, err
+	}
+	return r.FindByID(ctx, id)
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (users.User, error) {
+	return r.find(func(user users.User) bool { return user.ID == id })
+}
+
+func (r *Repository) FindByUsername(ctx context.Context, username string) (users.User, error) {
+	return r.find(func(user users.User) bool { return user.Username == username })
+}
+
+func (r *Repository) FindByEmail(ctx context.Context, email string) (users.User, error) {
+	return r.find(func(user users.User) bool { return email != "" && strings.EqualFold(user.Email, email) })
+}
+
+func (r *Repository) FindByLogin(ctx context.Context, login string) (users.User, error) {
+	if strings.Contains(login, "@") {
+		return r.FindByEmail(ctx, login)
+	}
+	return r.FindByUsername(ctx, login)
+}
+
+func (r *Repository) ChangeEmail(ctx context.Context, user users.User) error {
+	return r.update(user.ID, func(existing *users.User) {
+		existing.Email = user.Email
+		existing.EmailVerifiedAt = user.EmailVerifiedAt
+	})
+}
+
+func (r *Repository) AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history := append(r.history[userID], passwordHash)
+	if len(history) > keep {
+		history = history[len(history)-keep:]
+	}
+	r.history[userID] = history
+	return nil
+}
+
+func (r *Repository) RecentPasswordHashes(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := []string{}
+	history := r.history[userID]
+	for i := len(history) - 1; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, history[i])
+	}
+	return hashes, nil
+}
+
+// List retorna els usuaris ordenats pel nom d'usuari. Els filtres i el cursor
+// no s'apliquen
+func (r *Repository) List(ctx context.Context, filter users.UserFilter) ([]users.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []users.User{}
+	for _, user := range r.users {
+		list = append(list, user)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	return list, nil
+}
+
+func (r *Repository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	return r.update(id, func(user *users.User) { user.EmailVerifiedAt = &now })
+}
+
+func (r *Repository) Reactivate(ctx context.Context, id uuid.UUID) error {
+	return r.update(id, func(user *users.User) { user.IsActive = true })
+}
+
+func (r *Repository) Erase(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return users.ErrUserNotFound
+	}
+	delete(r.users, id)
+	delete(r.history, id)
+	return nil
+}
+
+func (r *Repository) find(match func(users.User) bool) (users.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if match(user) {
+			if !user.IsActive {
+				return users.User{}, users.ErrInactiveUser
+			}
+			return user, nil
+		}
+	}
+	return users.User{}, users.ErrUserNotFound
+}
+
+func (r *Repository) update(id uuid.UUID, change func(*users.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return users.ErrUserNotFound
+	}
+	change(&user)
+	r.users[id] = user
+	return nil
+}
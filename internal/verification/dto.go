@@ -0,0 +1,5 @@
+package verification
+
+type ResendRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
@@ -0,0 +1,8 @@
+package verification
+
+import "errors"
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired verification token")
+	ErrInvalidRequest = errors.New("invalid request")
+)
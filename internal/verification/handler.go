@@ -0,0 +1,43 @@
+package verification
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerificationHandler struct {
+	service VerificationService
+}
+
+func NewVerificationHandler(service VerificationService) *VerificationHandler {
+	return &VerificationHandler{service: service}
+}
+
+func (h *VerificationHandler) Verify(c *gin.Context) {
+	token := c.Query("token")
+	err := h.service.Verify(c.Request.Context(), token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func (h *VerificationHandler) Resend(c *gin.Context) {
+	var request ResendRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Resend(c.Request.Context(), request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is pending verification, a new link has been sent"})
+}
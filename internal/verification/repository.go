@@ -0,0 +1,88 @@
+package verification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type VerificationRepository interface {
+	Create(ctx context.Context, token VerificationToken) (VerificationToken, error)
+	InvalidateByUserID(ctx context.Context, userID uuid.UUID) error
+	Consume(ctx context.Context, tokenHash string) (VerificationToken, error)
+	FindPendingUserIDByEmail(ctx context.Context, email string) (uuid.UUID, error)
+}
+
+type verificationRepository struct {
+	db *sql.DB
+}
+
+func NewVerificationRepository(db *sql.DB) VerificationRepository {
+	return &verificationRepository{db: db}
+}
+
+func (r *verificationRepository) Create(ctx context.Context, token VerificationToken) (VerificationToken, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_verification_tokens (id, user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.ID, token.UserID, token.Email, token.TokenHash, token.ExpiresAt,
+	)
+	if err != nil {
+		return VerificationToken{}, fmt.Errorf("error inserting verification token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *verificationRepository) InvalidateByUserID(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = now()
+		WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error invalidating verification tokens: %w", err)
+	}
+	return nil
+}
+
+// Consume marca el token com a utilitzat en una sola sentència i el retorna
+func (r *verificationRepository) Consume(ctx context.Context, tokenHash string) (VerificationToken, error) {
+	var token VerificationToken
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING id, user_id, email, expires_at, used_at, created_at`,
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.Email, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return VerificationToken{}, ErrInvalidToken
+	} else if err != nil {
+		return VerificationToken{}, fmt.Errorf("error consuming verification token: %w", err)
+	}
+	return token, nil
+}
+
+// FindPendingUserIDByEmail retorna l'usuari sense verificar al qual es va enviar
+// l'últim correu de verificació a aquesta adreça
+func (r *verificationRepository) FindPendingUserIDByEmail(ctx context.Context, email string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `
+		SELECT t.user_id
+		FROM email_verification_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE lower(t.email) = lower($1) AND u.email_verified_at IS NULL
+		ORDER BY t.created_at DESC
+		LIMIT 1`,
+		email,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	} else if err != nil {
+		return uuid.Nil, fmt.Errorf("error getting pending verification: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,8 @@
+package verification
+
+import "github.com/gin-gonic/gin"
+
+func RegisterRoutes(router *gin.RouterGroup, handler *VerificationHandler) {
+	router.GET("/verify", handler.Verify)
+	router.POST("/verify/resend", handler.Resend)
+}
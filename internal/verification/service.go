@@ -0,0 +1,101 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"perretes-api/internal/mailer"
+	"perretes-api/internal/users"
+	"perretes-api/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VerificationService interface {
+	SendVerification(ctx context.Context, user users.User, email string) error
+	Verify(ctx context.Context, token string) error
+	Resend(ctx context.Context, request ResendRequest) error
+}
+
+type verificationService struct {
+	repo      VerificationRepository
+	userRepo  users.UserRepository
+	mailer    mailer.Mailer
+	verifyURL string
+	ttl       time.Duration
+}
+
+func NewVerificationService(repo VerificationRepository, userRepo users.UserRepository, mailer mailer.Mailer, verifyURL string, ttl time.Duration) VerificationService {
+	return &verificationService{
+		repo:      repo,
+		userRepo:  userRepo,
+		mailer:    mailer,
+		verifyURL: verifyURL,
+		ttl:       ttl,
+	}
+}
+
+// SendVerification invalida els tokens anteriors de l'usuari i n'envia un de nou
+func (s *verificationService) SendVerification(ctx context.Context, user users.User, email string) error {
+	if email == "" {
+		return ErrInvalidRequest
+	}
+	if err := s.repo.InvalidateByUserID(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Create(ctx, VerificationToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.verifyURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      []string{email},
+		Subject: "Verifica el teu correu",
+		Body: fmt.Sprintf("Hola %s,\n\nPer activar el teu compte obre aquest enllaç:\n%s\n\nL'enllaç caduca en %s.\n",
+			user.Username, link, s.ttl),
+	})
+}
+
+func (s *verificationService) Verify(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidRequest
+	}
+	verificationToken, err := s.repo.Consume(ctx, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(ctx, verificationToken.UserID)
+}
+
+// Resend torna a enviar el correu de verificació. No retorna error si l'adreça
+// no té cap verificació pendent, per no revelar quins correus estan registrats
+func (s *verificationService) Resend(ctx context.Context, request ResendRequest) error {
+	if request.Email == "" {
+		return ErrInvalidRequest
+	}
+	userID, err := s.repo.FindPendingUserIDByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+	if userID == uuid.Nil {
+		return nil
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerification(ctx, user, request.Email)
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// Camps del body que no s'han de guardar mai al log
+var sensitiveFields = []string{"password", "current_password", "new_password", "token", "refresh_token", "mfa_token", "code", "state"}
+
 type ActionLogMiddleware struct {
 	db *sql.DB
 }
@@ -46,7 +49,9 @@ func (alm *ActionLogMiddleware) LogAction() gin.HandlerFunc {
 				
 				var jsonBody map[string]interface{}
 				if err := json.Unmarshal(bodyBytes, &jsonBody); err == nil {					
-					delete(jsonBody, "password")					
+					for _, field := range sensitiveFields {
+						delete(jsonBody, field)
+					}
 					modifiedBodyBytes, err := json.Marshal(jsonBody)
 					if err == nil {
 						metadata = string(modifiedBodyBytes)
@@ -73,8 +78,16 @@ func (alm *ActionLogMiddleware) LogAction() gin.HandlerFunc {
 			}
 		}
 
+		// En una suplantació es guarda també l'administrador que fa la petició
+		var actorUUID *uuid.UUID
+		if actorID, ok := ImpersonatorID(c); ok {
+			if parsedUUID, err := uuid.Parse(actorID); err == nil {
+				actorUUID = &parsedUUID
+			}
+		}
+
 		// Guardar log
-		if err := alm.SaveActionLog(userUUID, actionType, metadata, timezone, performedAt); err != nil {
+		if err := alm.saveActionLog(userUUID, actorUUID, actionType, metadata, timezone, performedAt); err != nil {
 			// Potser vols fer un log aquí
 			log.Printf("Error saving action log: %v", err)
 		}
@@ -85,8 +98,12 @@ func (alm *ActionLogMiddleware) LogAction() gin.HandlerFunc {
 
 
 func (alm *ActionLogMiddleware) SaveActionLog(userID uuid.UUID, actionType, metadata, timezone string, performedAt time.Time) error {
-		query := `INSERT INTO action_logs (user_id, action_type, metadata, timezone, performed_at) 
-				VALUES ($1, $2, $3::jsonb, $4, $5)`
-		_, err := alm.db.Exec(query, userID, actionType, metadata, timezone, performedAt)
+		return alm.saveActionLog(userID, nil, actionType, metadata, timezone, performedAt)
+	}
+
+func (alm *ActionLogMiddleware) saveActionLog(userID uuid.UUID, actorID *uuid.UUID, actionType, metadata, timezone string, performedAt time.Time) error {
+		query := `INSERT INTO action_logs (user_id, actor_id, action_type, metadata, timezone, performed_at) 
+				VALUES ($1, $2, $3, $4::jsonb, $5, $6)`
+		_, err := alm.db.Exec(query, userID, actorID, actionType, metadata, timezone, performedAt)
 		return err
 	}
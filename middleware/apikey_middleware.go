@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"perretes-api/utils"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// APIKeyHeader és la capçalera amb què les integracions envien la clau d'API
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyClaim és el claim que identifica les peticions fetes amb una clau d'API
+const APIKeyClaim = "api_key_id"
+
+// APIKeyScopesClaim és el claim amb tots els scopes de la clau d'API
+const APIKeyScopesClaim = "api_key_scopes"
+
+var (
+	ErrInvalidAPIKey    = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotAllowed = errors.New("this endpoint can't be used with an API key")
+)
+
+// APIKeyMiddleware autentica les peticions que porten una clau d'API en lloc
+// del token JWT. La clau només dona els permisos dels seus scopes que el rol de
+// l'usuari encara té, de manera que si se li treu un permís la clau el perd
+type APIKeyMiddleware struct {
+	db *sql.DB
+}
+
+func NewAPIKeyMiddleware(db *sql.DB) *APIKeyMiddleware {
+	return &APIKeyMiddleware{db: db}
+}
+
+// Authenticate comprova la clau d'API si la petició en porta i, si no, passa la
+// petició al middleware JWT
+func (m *APIKeyMiddleware) Authenticate(jwtHandler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			jwtHandler(c)
+			return
+		}
+
+		prefix, secret, ok := utils.ParseAPIKey(key)
+		if !ok {
+			abortUnauthorized(c, ErrInvalidAPIKey)
+			return
+		}
+
+		var keyID, userID, secretHash, role string
+		var scopes, rolePermissions []string
+		var expiresAt *time.Time
+		err := m.db.QueryRowContext(c.Request.Context(), `
+			SELECT k.id, k.user_id, k.secret_hash, k.scopes, k.expires_at, r.name,
+				COALESCE((SELECT array_agg(p.name) FROM role_permissions rp
+					JOIN permissions p ON p.id = rp.permission_id
+					WHERE rp.role_id = u.role_id), '{}')
+			FROM api_keys k
+			JOIN users u ON u.id = k.user_id
+			JOIN roles r ON r.id = u.role_id
+			WHERE k.prefix = $1 AND k.revoked_at IS NULL AND u.is_active = true`, prefix,
+		).Scan(&keyID, &userID, &secretHash, pq.Array(&scopes), &expiresAt, &role, pq.Array(&rolePermissions))
+		if err == sql.ErrNoRows {
+			abortUnauthorized(c, ErrInvalidAPIKey)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading API key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(secretHash)) != 1 {
+			abortUnauthorized(c, ErrInvalidAPIKey)
+			return
+		}
+		if expiresAt != nil && time.Now().After(*expiresAt) {
+			abortUnauthorized(c, ErrInvalidAPIKey)
+			return
+		}
+
+		// Es fa servir el mateix format que els claims del token perquè la resta
+		// de middlewares i handlers no hagin de distingir-los
+		permissions := []interface{}{}
+		keyScopes := make([]interface{}, 0, len(scopes))
+		for _, scope := range scopes {
+			keyScopes = append(keyScopes, scope)
+			for _, p := range rolePermissions {
+				if scope == p {
+					permissions = append(permissions, scope)
+					break
+				}
+			}
+		}
+		c.Set("JWT_PAYLOAD", jwt.MapClaims{
+			IdentityKey:       userID,
+			"role":            role,
+			"permissions":     permissions,
+			APIKeyClaim:       keyID,
+			APIKeyScopesClaim: keyScopes,
+		})
+		c.Set(IdentityKey, userID)
+
+		// last_used_at és orientatiu, amb un minut de resolució n'hi ha prou
+		_, err = m.db.ExecContext(c.Request.Context(), `
+			UPDATE api_keys SET last_used_at = now()
+			WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, keyID)
+		if err != nil {
+			log.Printf("Error updating API key last use: %v", err)
+		}
+
+		c.Next()
+	}
+}
+
+// IsAPIKeyRequest indica si la petició s'ha autenticat amb una clau d'API
+func IsAPIKeyRequest(c *gin.Context) bool {
+	_, ok := jwt.ExtractClaims(c)[APIKeyClaim].(string)
+	return ok
+}
+
+// DenyAPIKeys rebutja les peticions fetes amb una clau d'API. Es fa servir a les
+// rutes del compte, perquè una clau no pugui crear-ne d'altres ni canviar la
+// contrasenya, les sessions o el MFA de l'usuari
+func DenyAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsAPIKeyRequest(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrAPIKeyNotAllowed.Error()})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"perretes-api/internal/authz"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SetCaller desa l'usuari del token al context de la petició perquè els
+// serveis puguin comprovar la propietat dels recursos
+func SetCaller() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := jwt.ExtractClaims(c)
+		id, _ := claims["id"].(string)
+		userID, err := uuid.Parse(id)
+		if err == nil {
+			role, _ := claims["role"].(string)
+			caller := authz.Caller{
+				ID:          userID,
+				Role:        role,
+				Permissions: ClaimPermissions(c),
+				APIKey:      IsAPIKeyRequest(c),
+				Scopes:      claimStrings(c, APIKeyScopesClaim),
+			}
+			c.Request = c.Request.WithContext(authz.WithCaller(c.Request.Context(), caller))
+		}
+		c.Next()
+	}
+}
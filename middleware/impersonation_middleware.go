@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrImpersonationReadOnly = errors.New("impersonation tokens are read-only")
+
+// ReadOnlyImpersonation només deixa fer consultes amb un token de suplantació.
+// Serveix per veure què veu l'usuari, no per actuar en nom seu, i així tampoc
+// es pot encadenar una suplantació amb una altra
+func ReadOnlyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := ImpersonatorID(c); ok {
+			switch c.Request.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrImpersonationReadOnly.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
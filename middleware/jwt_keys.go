@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"perretes-api/config"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnknownKey         = errors.New("token signed with an unknown key")
+	ErrUnexpectedAlg      = errors.New("unexpected token signing algorithm")
+	ErrUnsupportedKeyType = errors.New("unsupported key type, use RSA (2048 bits or more) or Ed25519")
+)
+
+// jwtKey és una clau pública amb què es verifiquen tokens. La clau activa també
+// porta la privada per signar-ne
+type jwtKey struct {
+	kid     string
+	method  gojwt.SigningMethod
+	public  crypto.PublicKey
+	private crypto.Signer
+}
+
+// KeySet són les claus amb què se signen i verifiquen els tokens. Sense clau
+// privada es fa servir HS256 amb JWT_SECRET. Amb clau privada els tokens porten
+// el kid a la capçalera i es poden verificar amb qualsevol de les claus
+// publicades, de manera que els tokens de la clau anterior continuen sent vàlids
+// mentre es fa la rotació
+type KeySet struct {
+	secret  []byte
+	signing *jwtKey
+	keys    map[string]*jwtKey
+}
+
+// JWK és una clau pública en el format de RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func LoadKeySet(cfg *config.Config) (*KeySet, error) {
+	keys := &KeySet{keys: map[string]*jwtKey{}}
+	if cfg.JWTPrivateKeyFile == "" {
+		keys.secret = []byte(cfg.JWTSecret)
+		return keys, nil
+	}
+
+	data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWT private key: %w", err)
+	}
+	private, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JWT private key: %w", err)
+	}
+	signing, err := newJWTKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	signing.private = private
+	keys.signing = signing
+	keys.keys[signing.kid] = signing
+
+	// Claus anteriors que encara s'accepten per verificar
+	for _, file := range cfg.JWTVerificationKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWT verification key %s: %w", file, err)
+		}
+		public, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JWT verification key %s: %w", file, err)
+		}
+		key, err := newJWTKey(public)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := keys.keys[key.kid]; !exists {
+			keys.keys[key.kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// Sign signa els claims amb la clau activa
+func (k *KeySet) Sign(claims gojwt.MapClaims) (string, error) {
+	if k.signing == nil {
+		return gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+	token := gojwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.kid
+	return token.SignedString(k.signing.private)
+}
+
+// Verify és el KeyFunc de gin-jwt: tria la clau pel kid i comprova que
+// l'algorisme del token és el d'aquesta clau
+func (k *KeySet) Verify(token *gojwt.Token) (interface{}, error) {
+	if k.signing == nil {
+		if token.Method != gojwt.SigningMethodHS256 {
+			return nil, ErrUnexpectedAlg
+		}
+		return k.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrUnexpectedAlg
+	}
+	return key.public, nil
+}
+
+// JWKS retorna les claus públiques de verificació. Amb HS256 no se'n publica cap
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if k.signing == nil {
+		return jwks
+	}
+	jwks.Keys = append(jwks.Keys, k.signing.jwk())
+	for kid, key := range k.keys {
+		if kid != k.signing.kid {
+			jwks.Keys = append(jwks.Keys, key.jwk())
+		}
+	}
+	return jwks
+}
+
+func newJWTKey(public crypto.PublicKey) (*jwtKey, error) {
+	key := &jwtKey{public: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, ErrUnsupportedKeyType
+		}
+		key.method = gojwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = gojwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+	key.kid = key.thumbprint()
+	return key, nil
+}
+
+func (k *jwtKey) jwk() JWK {
+	jwk := JWK{Use: "sig", Kid: k.kid, Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// thumbprint calcula el kid segons RFC 7638, així no cal configurar-lo i
+// és el mateix a totes les instàncies que comparteixen la clau
+func (k *jwtKey) thumbprint() string {
+	jwk := k.jwk()
+	// Només els membres obligatoris i en ordre alfabètic
+	var members map[string]string
+	if jwk.Kty == "RSA" {
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	} else {
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// parsePublicKey accepta una clau pública o, per comoditat, la privada anterior
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		private, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return private.Public(), nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
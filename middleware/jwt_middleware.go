@@ -7,23 +7,139 @@ import (
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	gojwt "github.com/golang-jwt/jwt/v4"
 )
 
-func SetupJWT(cfg *config.Config) (*jwt.GinJWTMiddleware, error) {
-    return jwt.New(&jwt.GinJWTMiddleware{
+// Identity és la informació de l'usuari autenticat que es desa com a claims del token
+type Identity struct {
+    ID          string
+    Role        string
+    Permissions []string
+    SessionID   string
+}
+
+// MFAPending identifica un usuari que ha entrat la contrasenya però encara ha
+// de completar la verificació en dos passos. El token només serveix a /auth/login/mfa
+type MFAPending struct {
+    UserID string
+}
+
+// Impersonation és la identitat d'un usuari que fa servir un administrador per
+// veure l'aplicació com la veu ell. El token porta l'administrador al claim act
+type Impersonation struct {
+    Identity
+    ActorID string
+}
+
+// ActorClaim és el claim amb l'usuari que fa realment la petició (RFC 8693)
+const ActorClaim = "act"
+
+// MFAPendingClaim marca els tokens que encara no donen accés a l'API
+const MFAPendingClaim = "mfa_pending"
+
+// IdentityKey és la clau amb què l'IdentityHandler desa l'ID de l'usuari al context
+const IdentityKey = "id"
+
+// CurrentUserID retorna l'ID de l'usuari que l'IdentityHandler ha resolt a partir del token
+func CurrentUserID(c *gin.Context) (string, bool) {
+    id, exists := c.Get(IdentityKey)
+    if !exists {
+        return "", false
+    }
+    v, ok := id.(string)
+    return v, ok && v != ""
+}
+
+// ImpersonatorID retorna l'administrador que fa la petició en nom de l'usuari del token
+func ImpersonatorID(c *gin.Context) (string, bool) {
+    return actorID(jwt.ExtractClaims(c))
+}
+
+func actorID(claims map[string]interface{}) (string, bool) {
+    act, ok := claims[ActorClaim].(map[string]interface{})
+    if !ok {
+        return "", false
+    }
+    sub, ok := act["sub"].(string)
+    return sub, ok && sub != ""
+}
+
+// JWTMiddleware és el middleware de gin-jwt amb les claus de signatura. gin-jwt
+// no sap posar el kid a la capçalera ni signar amb Ed25519, així que els tokens
+// es generen amb el TokenGenerator d'aquí i es verifiquen amb el KeyFunc del KeySet
+type JWTMiddleware struct {
+    *jwt.GinJWTMiddleware
+    Keys *KeySet
+}
+
+// TokenGenerator genera el token com el de gin-jwt però el signa amb la clau activa
+func (m *JWTMiddleware) TokenGenerator(data interface{}) (string, time.Time, error) {
+    claims := gojwt.MapClaims{}
+    for key, value := range m.PayloadFunc(data) {
+        claims[key] = value
+    }
+    expire := m.TimeFunc().Add(m.TimeoutFunc(claims))
+    claims[m.ExpField] = expire.Unix()
+    claims["orig_iat"] = m.TimeFunc().Unix()
+    token, err := m.Keys.Sign(claims)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return token, expire, nil
+}
+
+func SetupJWT(cfg *config.Config) (*JWTMiddleware, error) {
+    keys, err := LoadKeySet(cfg)
+    if err != nil {
+        return nil, err
+    }
+    mw, err := jwt.New(&jwt.GinJWTMiddleware{
         Realm:       "perretes-api",
-        Key:         []byte(cfg.JWTSecret),
-        Timeout:     time.Hour * 8,
-        MaxRefresh:  time.Hour * 24,
-        IdentityKey: "id",
+        KeyFunc:     keys.Verify,
+        // Els access tokens duren poc: es renoven amb el refresh token a /auth/refresh
+        Timeout:     cfg.AccessTokenTTL,
+        IdentityKey: IdentityKey,
         PayloadFunc: func(data interface{}) jwt.MapClaims {
-            if v, ok := data.(string); ok {
+            if v, ok := data.(Identity); ok {
                 return jwt.MapClaims{
-                    "id": v,
+                    "id":          v.ID,
+                    "role":        v.Role,
+                    "permissions": v.Permissions,
+                    "iat":         time.Now().Unix(),
+                    "jti":         v.SessionID,
+                }
+            }
+            if v, ok := data.(Impersonation); ok {
+                return jwt.MapClaims{
+                    "id":          v.ID,
+                    "role":        v.Role,
+                    "permissions": v.Permissions,
+                    "iat":         time.Now().Unix(),
+                    "jti":         v.SessionID,
+                    ActorClaim:    map[string]interface{}{"sub": v.ActorID},
+                }
+            }
+            if v, ok := data.(MFAPending); ok {
+                return jwt.MapClaims{
+                    "id":           v.UserID,
+                    MFAPendingClaim: true,
+                    "iat":          time.Now().Unix(),
                 }
             }
             return jwt.MapClaims{}
         },
+        // Els tokens de verificació pendent i els de suplantació caduquen en
+        // pocs minuts. El TokenGenerator de gin-jwt passa els claims de golang-jwt
+        TimeoutFunc: func(data interface{}) time.Duration {
+            claims, _ := data.(gojwt.MapClaims)
+            if claims[MFAPendingClaim] == true {
+                return cfg.MFATokenTTL
+            }
+            if _, ok := actorID(claims); ok {
+                return cfg.ImpersonationTTL
+            }
+            return cfg.AccessTokenTTL
+        },
         IdentityHandler: func(c *gin.Context) interface{} {
             claims := jwt.ExtractClaims(c)
             if id, exists := claims["id"]; exists && id != nil {
@@ -37,10 +153,13 @@ func SetupJWT(cfg *config.Config) (*jwt.GinJWTMiddleware, error) {
             // Aquest codi no s'utilitzarà directament, sinó a través del servei d'autenticació
             return nil, jwt.ErrFailedAuthentication
         },
-        // Verificar si l'usuari té accés a una ruta específica
+        // Verificar que el token identifica un usuari. Els permisos de cada
+        // ruta es comproven amb RequirePermission. Un token de verificació
+        // pendent no dona accés a res
         Authorizator: func(data interface{}, c *gin.Context) bool {
-            // Aquí pots implementar una lògica més complexa de verificació de permisos
-            // Per exemple, verificar el rol de l'usuari per a rutes específiques
+            if pending, _ := jwt.ExtractClaims(c)[MFAPendingClaim].(bool); pending {
+                return false
+            }
             return data != nil
         },
         Unauthorized: func(c *gin.Context, code int, message string) {
@@ -49,8 +168,13 @@ func SetupJWT(cfg *config.Config) (*jwt.GinJWTMiddleware, error) {
                 "message": message,
             })
         },
-        TokenLookup:   "header: Authorization, query: token, cookie: jwt",
+        // Només per capçalera: els tokens a la URL acaben als logs
+        TokenLookup:   "header: Authorization",
         TokenHeadName: "Bearer",
         TimeFunc:      time.Now,
     })
+    if err != nil {
+        return nil, err
+    }
+    return &JWTMiddleware{GinJWTMiddleware: mw, Keys: keys}, nil
 }
\ No newline at end of file
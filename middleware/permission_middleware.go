@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrForbidden = errors.New("you don't have permission to access this resource")
+
+// RequirePermission només deixa passar les peticions el token de les quals
+// inclou tots els permisos indicats
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if !HasPermission(c, permission) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrForbidden.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// HasPermission indica si el token de la petició inclou el permís
+func HasPermission(c *gin.Context, permission string) bool {
+	for _, p := range ClaimPermissions(c) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimPermissions retorna la llista de permisos guardada al token
+func ClaimPermissions(c *gin.Context) []string {
+	return claimStrings(c, "permissions")
+}
+
+// claimStrings retorna el claim indicat com a llista de cadenes
+func claimStrings(c *gin.Context, claim string) []string {
+	claims := jwt.ExtractClaims(c)
+	raw, ok := claims[claim].([]interface{})
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
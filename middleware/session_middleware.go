@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrMissingSession = errors.New("token has no session")
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// SessionMiddleware rebutja els tokens la sessió dels quals (claim jti) s'ha
+// revocat i actualitza quan es va veure la sessió per últim cop
+type SessionMiddleware struct {
+	db *sql.DB
+}
+
+func NewSessionMiddleware(db *sql.DB) *SessionMiddleware {
+	return &SessionMiddleware{db: db}
+}
+
+func (m *SessionMiddleware) CheckSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Les claus d'API no van lligades a cap sessió
+		if IsAPIKeyRequest(c) {
+			c.Next()
+			return
+		}
+		claims := jwt.ExtractClaims(c)
+		jti, _ := claims["jti"].(string)
+		userID, _ := claims[IdentityKey].(string)
+		// Els tokens de suplantació van lligats a la sessió de l'administrador
+		if actor, ok := ImpersonatorID(c); ok {
+			userID = actor
+		}
+		if jti == "" {
+			abortUnauthorized(c, ErrMissingSession)
+			return
+		}
+
+		var revokedAt *time.Time
+		var lastSeenAt time.Time
+		err := m.db.QueryRowContext(c.Request.Context(), `
+			SELECT revoked_at, last_seen_at FROM sessions WHERE id = $1 AND user_id = $2`,
+			jti, userID,
+		).Scan(&revokedAt, &lastSeenAt)
+		if err == sql.ErrNoRows {
+			abortUnauthorized(c, ErrSessionRevoked)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading session: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if revokedAt != nil {
+			abortUnauthorized(c, ErrSessionRevoked)
+			return
+		}
+
+		// Només s'actualitza un cop per minut per no escriure a cada petició
+		if time.Since(lastSeenAt) > time.Minute {
+			_, err := m.db.ExecContext(c.Request.Context(), `
+				UPDATE sessions SET last_seen_at = now(), ip_address = $2 WHERE id = $1`,
+				jti, c.ClientIP())
+			if err != nil {
+				log.Printf("Error updating session: %v", err)
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrTokenRevoked  = errors.New("token is no longer valid, please log in again")
+	ErrUserInactive  = errors.New("user is not active")
+	ErrMissingIssued = errors.New("token has no issue date")
+)
+
+type userStatus struct {
+	isActive          bool
+	passwordChangedAt *time.Time
+	loadedAt          time.Time
+}
+
+// UserStatusMiddleware rebutja els tokens d'usuaris desactivats o emesos abans
+// de l'últim canvi de contrasenya. L'estat de cada usuari es guarda uns segons
+// en memòria per no consultar la base de dades a cada petició
+type UserStatusMiddleware struct {
+	db    *sql.DB
+	ttl   time.Duration
+	mu    sync.Mutex
+	cache map[string]userStatus
+}
+
+func NewUserStatusMiddleware(db *sql.DB, ttl time.Duration) *UserStatusMiddleware {
+	return &UserStatusMiddleware{
+		db:    db,
+		ttl:   ttl,
+		cache: make(map[string]userStatus),
+	}
+}
+
+func (m *UserStatusMiddleware) CheckUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// L'APIKeyMiddleware ja ha comprovat que l'usuari de la clau està actiu
+		if IsAPIKeyRequest(c) {
+			c.Next()
+			return
+		}
+		claims := jwt.ExtractClaims(c)
+		id, _ := claims[IdentityKey].(string)
+
+		issuedAt, ok := claimTime(claims, "iat")
+		if !ok {
+			// Tokens emesos abans que s'afegís iat
+			issuedAt, ok = claimTime(claims, "orig_iat")
+		}
+		if !ok {
+			abortUnauthorized(c, ErrMissingIssued)
+			return
+		}
+
+		// En una suplantació també ha de seguir sent vàlid l'administrador
+		ids := []string{id}
+		if actor, ok := ImpersonatorID(c); ok {
+			ids = append(ids, actor)
+		}
+		for _, id := range ids {
+			status, err := m.load(c, id)
+			if err == sql.ErrNoRows {
+				abortUnauthorized(c, ErrTokenRevoked)
+				return
+			}
+			if err != nil {
+				log.Printf("Error loading user status: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			if !status.isActive {
+				abortUnauthorized(c, ErrUserInactive)
+				return
+			}
+			// iat té resolució de segons, per això es compara amb el segon del canvi
+			if status.passwordChangedAt != nil && issuedAt < status.passwordChangedAt.Unix() {
+				abortUnauthorized(c, ErrTokenRevoked)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func (m *UserStatusMiddleware) load(c *gin.Context, id string) (userStatus, error) {
+	m.mu.Lock()
+	status, found := m.cache[id]
+	m.mu.Unlock()
+	if found && time.Since(status.loadedAt) < m.ttl {
+		return status, nil
+	}
+
+	err := m.db.QueryRowContext(c.Request.Context(), `
+		SELECT is_active, password_changed_at FROM users WHERE id = $1`, id,
+	).Scan(&status.isActive, &status.passwordChangedAt)
+	if err != nil {
+		return userStatus{}, err
+	}
+	status.loadedAt = time.Now()
+
+	m.mu.Lock()
+	// Evitar que la memòria creixi sense límit
+	if len(m.cache) > 10000 {
+		m.cache = make(map[string]userStatus)
+	}
+	m.cache[id] = status
+	m.mu.Unlock()
+	return status, nil
+}
+
+func claimTime(claims jwt.MapClaims, key string) (int64, bool) {
+	switch v := claims[key].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+func abortUnauthorized(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"code":    http.StatusUnauthorized,
+		"message": err.Error(),
+	})
+}
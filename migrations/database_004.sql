@@ -0,0 +1,57 @@
+CREATE TABLE roles (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    name varchar(50) NOT NULL UNIQUE,
+    description varchar(250)
+);
+
+CREATE TABLE permissions (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL UNIQUE,
+    description varchar(250)
+);
+
+CREATE TABLE role_permissions (
+    role_id uuid NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id uuid NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Administrador amb accés complet'),
+    ('trainer', 'Educador que gestiona cursos i inscripcions'),
+    ('customer', 'Client propietari de gossos');
+
+INSERT INTO permissions (name, description) VALUES
+    ('courses:read', 'Consultar cursos i classes'),
+    ('courses:write', 'Crear, modificar i esborrar cursos i classes'),
+    ('enrollments:read', 'Consultar inscripcions i progrés de qualsevol usuari'),
+    ('enrollments:write', 'Gestionar inscripcions i progrés de qualsevol usuari'),
+    ('customers:read', 'Consultar clients'),
+    ('customers:write', 'Crear, modificar i esborrar clients'),
+    ('users:write', 'Crear, modificar i desactivar usuaris');
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+    WHERE r.name = 'admin';
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p
+    ON p.name IN ('courses:read', 'courses:write', 'enrollments:read', 'enrollments:write', 'customers:read')
+    WHERE r.name = 'trainer';
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p
+    ON p.name IN ('courses:read')
+    WHERE r.name = 'customer';
+
+ALTER TABLE users ADD COLUMN role_id uuid REFERENCES roles(id);
+
+UPDATE users SET role_id = (SELECT id FROM roles WHERE name = 'customer') WHERE is_customer = true;
+-- El personal existent passa a ser educador. El rol d'administrador s'ha de donar
+-- explícitament a qui el necessiti
+UPDATE users SET role_id = (SELECT id FROM roles WHERE name = 'trainer') WHERE role_id IS NULL;
+
+ALTER TABLE users ALTER COLUMN role_id SET NOT NULL;
+ALTER TABLE users DROP COLUMN is_customer;
+
+CREATE INDEX idx_users_role_id ON users(role_id);
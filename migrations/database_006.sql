@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+
+-- Els comptes existents es consideren verificats
+UPDATE users SET email_verified_at = now();
+
+CREATE TABLE email_verification_tokens (
+    id uuid PRIMARY KEY NOT NULL,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email varchar(250) NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+CREATE INDEX idx_email_verification_tokens_email ON email_verification_tokens(lower(email));
@@ -0,0 +1,13 @@
+-- Cada sessió és una família de refresh tokens: si se'n reutilitza un, es revoca tota la sessió
+CREATE TABLE refresh_tokens (
+    id uuid PRIMARY KEY NOT NULL,
+    session_id uuid NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
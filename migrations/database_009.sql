@@ -0,0 +1,10 @@
+CREATE TABLE login_throttles (
+    scope varchar(20) NOT NULL,
+    key varchar(250) NOT NULL,
+    failures int NOT NULL DEFAULT 0,
+    last_failure_at timestamptz,
+    blocked_until timestamptz,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idx_login_throttles_blocked_until ON login_throttles(blocked_until);
@@ -0,0 +1,26 @@
+-- Identitats de proveïdors externs (OpenID Connect) enllaçades a un usuari
+CREATE TABLE identities (
+    id uuid PRIMARY KEY NOT NULL,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email varchar(250),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    last_login_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_identities_user_id ON identities(user_id);
+
+-- Estat de cada login amb un proveïdor extern: el verificador PKCE i el nonce
+-- es queden al servidor i el navegador només porta el state
+CREATE TABLE oidc_login_states (
+    id uuid PRIMARY KEY NOT NULL,
+    state_hash varchar(64) NOT NULL UNIQUE,
+    provider varchar(50) NOT NULL,
+    code_verifier varchar(128) NOT NULL,
+    nonce varchar(128) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
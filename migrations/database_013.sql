@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email varchar(250);
+
+-- Copiar el correu de cada client al seu usuari. Si diversos clients comparteixen
+-- correu només el rep el primer, perquè ha de ser únic
+UPDATE users u SET email = c.email
+FROM (
+    SELECT DISTINCT ON (lower(email)) user_id, email
+    FROM customers
+    WHERE user_id IS NOT NULL AND email IS NOT NULL AND email <> ''
+    ORDER BY lower(email), is_active DESC, id
+) c
+WHERE u.id = c.user_id;
+
+CREATE UNIQUE INDEX idx_users_email ON users(lower(email));
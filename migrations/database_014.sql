@@ -0,0 +1,12 @@
+CREATE TABLE password_history (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash varchar(255) NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_password_history_user_id ON password_history(user_id, created_at DESC);
+
+-- La contrasenya actual de cada usuari és la primera entrada de l'historial
+INSERT INTO password_history (user_id, password_hash, created_at)
+    SELECT id, password, COALESCE(password_changed_at, now()) FROM users;
@@ -0,0 +1,11 @@
+ALTER TABLE action_logs ADD COLUMN actor_id uuid;
+
+CREATE INDEX idx_action_actor ON action_logs(actor_id) WHERE actor_id IS NOT NULL;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Veure l''aplicació com la veu un altre usuari');
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p
+    ON p.name = 'users:impersonate'
+    WHERE r.name = 'admin';
@@ -0,0 +1,27 @@
+CREATE TABLE dogs (
+    id uuid PRIMARY KEY NOT NULL,
+    customer_id uuid NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    breed varchar(100),
+    birth_date date,
+    sex varchar(10) CHECK (sex IN ('male', 'female')),
+    weight_kg numeric(5,2),
+    neutered bool DEFAULT false,
+    microchip_number varchar(20),
+    photo_url varchar(500),
+    behaviour_notes text,
+    is_active bool DEFAULT true,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_dogs_customer_id ON dogs(customer_id);
+CREATE UNIQUE INDEX idx_dogs_microchip_number ON dogs(microchip_number) WHERE microchip_number IS NOT NULL AND is_active = true;
+
+ALTER TABLE course_enrollments ADD COLUMN dog_id uuid REFERENCES dogs(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_course_enrollments_dog_id ON course_enrollments(dog_id);
+
+-- Un client pot inscriure cadascun dels seus gossos al mateix curs
+DROP INDEX idx_course_enrollments_user_course;
+CREATE UNIQUE INDEX idx_course_enrollments_user_course_dog
+    ON course_enrollments(user_id, course_id, COALESCE(dog_id, '00000000-0000-0000-0000-000000000000'::uuid));
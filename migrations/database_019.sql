@@ -0,0 +1,15 @@
+CREATE TABLE dog_health_records (
+    id uuid PRIMARY KEY NOT NULL,
+    dog_id uuid NOT NULL REFERENCES dogs(id) ON DELETE CASCADE,
+    type varchar(20) NOT NULL CHECK (type IN ('vaccination', 'deworming')),
+    name varchar(100) NOT NULL,
+    administered_at date NOT NULL,
+    expires_at date NOT NULL,
+    veterinarian varchar(200),
+    notes text,
+    reminder_sent_at timestamptz,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_dog_health_records_dog_id ON dog_health_records(dog_id, type, expires_at DESC);
+CREATE INDEX idx_dog_health_records_expires_at ON dog_health_records(expires_at);
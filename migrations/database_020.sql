@@ -0,0 +1,17 @@
+CREATE TABLE training_diary_entries (
+    id uuid PRIMARY KEY NOT NULL,
+    enrollment_id uuid NOT NULL REFERENCES course_enrollments(id) ON DELETE CASCADE,
+    class_id uuid NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    practiced_on date NOT NULL,
+    duration_minutes int NOT NULL CHECK (duration_minutes > 0),
+    rating smallint NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    notes text,
+    attachment_url varchar(500),
+    attachment_type varchar(10) CHECK (attachment_type IN ('photo', 'video')),
+    created_by uuid REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_training_diary_entries_enrollment_class
+    ON training_diary_entries(enrollment_id, class_id, practiced_on DESC);
+CREATE INDEX idx_training_diary_entries_class_id ON training_diary_entries(class_id);
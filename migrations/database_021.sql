@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE customers ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX idx_customers_created_at ON customers(created_at, id);
+CREATE INDEX idx_customers_search ON customers USING gin (
+    lower(name || ' ' || surname || ' ' || COALESCE(email, '') || ' ' || COALESCE(phone_number, '')) gin_trgm_ops
+);
@@ -0,0 +1,3 @@
+-- Un login iniciat des del compte enllaça la identitat amb l'usuari que l'ha
+-- començat, en lloc de buscar-lo pel correu
+ALTER TABLE oidc_login_states ADD COLUMN user_id uuid REFERENCES users(id) ON DELETE CASCADE;
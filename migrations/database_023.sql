@@ -0,0 +1,12 @@
+-- Els comptes antics que feien servir el correu com a nom d'usuari i no en tenen
+-- cap de propi el reben, si cap altre usuari no el fa servir. Així poden
+-- continuar entrant amb el correu ara que els logins amb @ es busquen pel correu
+UPDATE users u SET email = c.username
+FROM (
+    SELECT DISTINCT ON (lower(username)) id, username
+    FROM users
+    WHERE username LIKE '%@%' AND email IS NULL
+    ORDER BY lower(username), is_active DESC, id
+) c
+WHERE u.id = c.id
+    AND NOT EXISTS (SELECT 1 FROM users e WHERE lower(e.email) = lower(c.username));
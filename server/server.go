@@ -1,22 +1,40 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"perretes-api/config"
+	"perretes-api/internal/account"
+	"perretes-api/internal/apikeys"
 	"perretes-api/internal/auth"
 	"perretes-api/internal/courses"
 	"perretes-api/internal/customers"
+	"perretes-api/internal/dogs"
 	"perretes-api/internal/health"
+	"perretes-api/internal/healthrecords"
+	"perretes-api/internal/jwks"
+	"perretes-api/internal/loginguard"
+	"perretes-api/internal/magiclink"
+	"perretes-api/internal/mailer"
+	"perretes-api/internal/mfa"
+	"perretes-api/internal/passwordpolicy"
+	"perretes-api/internal/passwordreset"
+	"perretes-api/internal/roles"
+	"perretes-api/internal/sessions"
+	"perretes-api/internal/sociallogin"
+	"perretes-api/internal/txn"
 	"perretes-api/internal/users"
+	"perretes-api/internal/verification"
 	"perretes-api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	router *gin.Engine
-	cfg    *config.Config
-	db     *sql.DB
+	router         *gin.Engine
+	cfg            *config.Config
+	db             *sql.DB
+	healthReminder *healthrecords.ReminderJob
 }
 
 func NewServer(cfg *config.Config, db *sql.DB) *Server {
@@ -38,54 +56,157 @@ func (s *Server) Setup() error {
 		return err
 	}
 
+	// Comprovació de l'estat de l'usuari i de la sessió del token
+	userStatusMiddleware := middleware.NewUserStatusMiddleware(s.db, s.cfg.UserStatusCacheTTL)
+	sessionMiddleware := middleware.NewSessionMiddleware(s.db)
+
+	// Autenticació amb clau d'API per a integracions
+	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(s.db)
+
 	// Action log middleware
 	actionLogMiddleware := middleware.NewActionLogMiddleware(s.db)
 	
 	// Inicialitzar repositoris
 	userRepo := users.NewUserRepository(s.db)
+	roleRepo := roles.NewRoleRepository(s.db)
 	customerRepo := customers.NewCustomerRepository(s.db)
 	coursesRepo := courses.NewCourseRepository(s.db)
+	resetTokenRepo := passwordreset.NewResetTokenRepository(s.db)
+	verificationRepo := verification.NewVerificationRepository(s.db)
+	sessionRepo := sessions.NewSessionRepository(s.db)
+	throttleRepo := loginguard.NewThrottleRepository(s.db)
+	mfaRepo := mfa.NewMFARepository(s.db)
+	loginTokenRepo := magiclink.NewLoginTokenRepository(s.db)
+	identityRepo := sociallogin.NewIdentityRepository(s.db)
+	apiKeyRepo := apikeys.NewAPIKeyRepository(s.db)
+	dogRepo := dogs.NewDogRepository(s.db)
+	healthRecordRepo := healthrecords.NewHealthRecordRepository(s.db)
+
+	// Correu electrònic
+	mail := mailer.New(s.cfg)
+
+	// Transaccions que comparteixen diversos repositoris
+	uow := txn.NewUnitOfWork(s.db)
 
 	// Inicialitzar serveis
-	userService := users.NewUserService(userRepo)
-	authService := auth.NewAuthService(userRepo, authMiddleware)
-	customerService := customers.NewCustomerService(customerRepo, userService)
+	verificationService := verification.NewVerificationService(verificationRepo, userRepo, mail, s.cfg.ApiURL+"/auth/verify", s.cfg.EmailVerificationTTL)
+	userService := users.NewUserService(userRepo, verificationService, passwordpolicy.Policy{
+		MinLength:     s.cfg.PasswordMinLength,
+		RequireUpper:  s.cfg.PasswordRequireUpper,
+		RequireLower:  s.cfg.PasswordRequireLower,
+		RequireDigit:  s.cfg.PasswordRequireDigit,
+		RequireSymbol: s.cfg.PasswordRequireSymbol,
+		HistorySize:   s.cfg.PasswordHistory,
+	})
+	sessionService := sessions.NewSessionService(sessionRepo)
+	loginGuard := loginguard.NewLoginGuard(throttleRepo, actionLogMiddleware,
+		loginguard.Policy{DelayAfter: s.cfg.LoginDelayAfter, LockAfter: s.cfg.LoginLockAfter, Lockout: s.cfg.LoginLockout},
+		loginguard.Policy{DelayAfter: s.cfg.LoginIPDelayAfter, LockAfter: s.cfg.LoginIPLockAfter, Lockout: s.cfg.LoginLockout},
+	)
+	mfaService := mfa.NewMFAService(mfaRepo, userRepo, s.cfg.MFAIssuer)
+	authService, err := auth.NewAuthService(userRepo, roleRepo, sessionService, loginGuard, mfaService, authMiddleware, auth.Settings{
+		RequireVerifiedEmail: s.cfg.RequireEmailVerification,
+		RefreshTokenTTL:      s.cfg.RefreshTokenTTL,
+	})
+	if err != nil {
+		return err
+	}
+	customerService := customers.NewCustomerService(customerRepo, userService, uow)
 	coursesService := courses.NewCourseService(coursesRepo)
+	oidcRedirectURL := s.cfg.OIDCRedirectURL
+	if oidcRedirectURL == "" {
+		oidcRedirectURL = s.cfg.FrontendURL + "/oidc/callback"
+	}
+	socialLoginService := sociallogin.NewSocialLoginService(identityRepo, userRepo, customerService, authService, uow, sociallogin.ProviderConfig{
+		Name:         s.cfg.OIDCProvider,
+		IssuerURL:    s.cfg.OIDCIssuerURL,
+		ClientID:     s.cfg.OIDCClientID,
+		ClientSecret: s.cfg.OIDCClientSecret,
+		RedirectURL:  oidcRedirectURL,
+	})
+	magicLinkGuard := loginguard.NewMagicLinkGuard(throttleRepo,
+		loginguard.Policy{DelayAfter: s.cfg.MagicLinkDelayAfter, LockAfter: s.cfg.MagicLinkLockAfter, Lockout: s.cfg.MagicLinkLockout},
+		loginguard.Policy{DelayAfter: s.cfg.MagicLinkIPDelayAfter, LockAfter: s.cfg.MagicLinkIPLockAfter, Lockout: s.cfg.MagicLinkLockout},
+	)
+	magicLinkService := magiclink.NewMagicLinkService(loginTokenRepo, userRepo, authService, magicLinkGuard, mail, s.cfg.FrontendURL+"/magic-link", s.cfg.MagicLinkTTL)
+	passwordResetService := passwordreset.NewPasswordResetService(resetTokenRepo, userRepo, userService, mail, s.cfg.FrontendURL+"/reset-password", s.cfg.PasswordResetTTL)
+	apiKeyService := apikeys.NewAPIKeyService(apiKeyRepo)
+	dogService := dogs.NewDogService(dogRepo, customerRepo)
+	healthRecordService := healthrecords.NewHealthRecordService(healthRecordRepo, dogService)
+
+	// Avisos de vacunes i desparasitacions que caduquen
+	s.healthReminder = healthrecords.NewReminderJob(healthRecordRepo, mail, s.cfg.HealthReminderDays, s.cfg.HealthReminderInterval)
 
 
 
 	// Inicialitzar handlers
-	userHandler := users.NewUserHandler(userService)
+	userHandler := users.NewUserHandler(userService, s.cfg.RequireEmailVerification)
 	authHandler := auth.NewAuthHandler(authService, authMiddleware)
 	customerHandler := customers.NewCustomerHandler(customerService)
 	coursesHandler := courses.NewCourseHandler(coursesService)
+	accountHandler := account.NewAccountHandler(userService, customerService, coursesService)
+	passwordResetHandler := passwordreset.NewPasswordResetHandler(passwordResetService)
+	verificationHandler := verification.NewVerificationHandler(verificationService)
+	sessionHandler := sessions.NewSessionHandler(sessionService)
+	mfaHandler := mfa.NewMFAHandler(mfaService)
+	socialLoginHandler := sociallogin.NewSocialLoginHandler(socialLoginService)
+	magicLinkHandler := magiclink.NewMagicLinkHandler(magicLinkService)
+	jwksHandler := jwks.NewJWKSHandler(authMiddleware.Keys)
+	apiKeyHandler := apikeys.NewAPIKeyHandler(apiKeyService)
+	dogHandler := dogs.NewDogHandler(dogService)
+	healthRecordHandler := healthrecords.NewHealthRecordHandler(healthRecordService)
 
 
 	
+	// Claus públiques per verificar els tokens
+	jwks.RegisterRoutes(s.router, jwksHandler)
+
 	// Configurar les rutes públiques (sense autenticació)
 	public := s.router.Group("/auth")
 	public.Use(actionLogMiddleware.LogAction())
 	public.GET("/health", health.CheckHealth)
 	users.RegisterPublicRoutes(public, userHandler)
-	auth.RegisterRoutes(public, authHandler, authMiddleware)
+	auth.RegisterRoutes(public, authHandler)
+	sociallogin.RegisterRoutes(public, socialLoginHandler)
+	magiclink.RegisterRoutes(public, magicLinkHandler)
+	passwordreset.RegisterRoutes(public, passwordResetHandler)
+	verification.RegisterRoutes(public, verificationHandler)
+	sessions.RegisterLogoutRoute(public, sessionHandler, authMiddleware.MiddlewareFunc(), sessionMiddleware.CheckSession())
 
 
 	// Configurar les rutes protegides (amb autenticació JWT)
 	protected := s.router.Group("/api")
-	protected.Use(authMiddleware.MiddlewareFunc())
+	protected.Use(apiKeyMiddleware.Authenticate(authMiddleware.MiddlewareFunc()))
+	protected.Use(userStatusMiddleware.CheckUser())
+	protected.Use(sessionMiddleware.CheckSession())
+	protected.Use(middleware.SetCaller())
 	protected.Use(actionLogMiddleware.LogAction())
+	protected.Use(middleware.ReadOnlyImpersonation())
 	
 
 	// Registrar les rutes protegides
 	users.RegisterRoutes(protected, userHandler)
 	customers.RegisterRoutes(protected, customerHandler)
+	dogs.RegisterRoutes(protected, dogHandler)
+	healthrecords.RegisterRoutes(protected, healthRecordHandler)
 	courses.RegisterRoutes(protected, coursesHandler)
+	auth.RegisterAdminRoutes(protected, authHandler)
+
+	// Les rutes del compte no es poden fer servir amb una clau d'API
+	me := protected.Group("", middleware.DenyAPIKeys())
+	account.RegisterRoutes(me, accountHandler)
+	sessions.RegisterRoutes(me, sessionHandler)
+	mfa.RegisterRoutes(me, mfaHandler)
+	apikeys.RegisterRoutes(me, apiKeyHandler)
+	sociallogin.RegisterAccountRoutes(me, socialLoginHandler)
 
 	
 	return nil
 }
 
 func (s *Server) Run() error {
+	go s.healthReminder.Run(context.Background())
+
 	//return s.router.RunTLS(":" + s.cfg.ApiPort, "./certs/cert.pem", "./certs/key.pem")
 	return s.router.Run(":" + s.cfg.ApiPort)
 }
\ No newline at end of file
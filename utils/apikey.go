@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix identifica les claus d'API, així es poden reconèixer si s'escapen
+// en un repositori o un log
+const APIKeyPrefix = "prk"
+
+// GenerateAPIKey genera una clau d'API amb el format prk_<prefix>_<secret>. El
+// prefix serveix per trobar la clau i mostrar-la, només es guarda el hash del secret
+func GenerateAPIKey() (key, prefix, secret string, err error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(b)
+	secret, err = GenerateToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	return APIKeyPrefix + "_" + prefix + "_" + secret, prefix, secret, nil
+}
+
+// ParseAPIKey separa el prefix i el secret d'una clau d'API
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// EncodeCursor codifica la posició de l'últim element d'una pàgina. El client
+// l'ha de tornar tal qual per demanar la pàgina següent
+func EncodeCursor(values ...string) string {
+	data, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor recupera els valors d'un cursor generat amb EncodeCursor
+func DecodeCursor(cursor string, n int) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != n {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
+
+// EscapeLike escapa els comodins d'un text que es fa servir dins d'un LIKE
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}